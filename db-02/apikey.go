@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const xAPIKeyHeaderKey = "X-API-Key"
+
+const apiKeyPrefix = "ck_"
+
+const apiKeyLastUsedResolution = time.Minute
+
+var errAPIKeyNotFound = errors.New("api key not found")
+
+type apiKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`
+	Scopes     []string   `json:"scopes"`
+	Hash       []byte     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type createdAPIKey struct {
+	apiKey
+	Key string `json:"key"`
+}
+
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	OwnerID   string     `json:"owner_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key apiKey) (apiKey, error)
+	AuthenticateAPIKey(ctx context.Context, hash []byte) (apiKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+}
+
+func newAPIKeySecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashAPIKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+func apiKeyUsed(key apiKey, now time.Time) bool {
+	return key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution
+}
+
+func formatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func parseScopes(value string) []string {
+	return strings.Fields(value)
+}
+
+func validateAPIKeyRequest(lang string, req apiKeyRequest, now time.Time) error {
+	required := validation.Required.Error(translate(lang, msgValidationRequired))
+
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, required, validation.Length(1, 100).Error(translate(lang, msgValidationLength, 1, 100))),
+		validation.Field(&req.OwnerID, required, validation.Length(1, 255).Error(translate(lang, msgValidationLength, 1, 255))),
+		validation.Field(&req.Scopes, required, validation.By(knownScopesRule(lang))),
+		validation.Field(&req.ExpiresAt, validation.By(futureTimeRule(lang, now))),
+	)
+}
+
+func knownScopesRule(lang string) validation.RuleFunc {
+	return func(value interface{}) error {
+		for _, scope := range value.([]string) {
+			if !slices.Contains(knownScopes, scope) {
+				return errors.New(translate(lang, msgValidationScopeUnknown, scope))
+			}
+		}
+
+		return nil
+	}
+}
+
+func futureTimeRule(lang string, now time.Time) validation.RuleFunc {
+	return func(value interface{}) error {
+		at, _ := value.(*time.Time)
+		if at != nil && !at.After(now) {
+			return errors.New(translate(lang, msgValidationNotInFuture))
+		}
+
+		return nil
+	}
+}
+
+func authenticateAPIKey(ctx context.Context, store APIKeyStore, header http.Header) (principal, error) {
+	key, err := store.AuthenticateAPIKey(ctx, hashAPIKey(header.Get(xAPIKeyHeaderKey)))
+	if err != nil {
+		return principal{}, err
+	}
+
+	return principal{Subject: key.OwnerID, APIKeyID: key.ID, Scopes: key.Scopes}, nil
+}
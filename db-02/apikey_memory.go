@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type memoryAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    []apiKey
+	revoked map[int]bool
+	now     func() time.Time
+}
+
+func newMemoryAPIKeyStore() *memoryAPIKeyStore {
+	return &memoryAPIKeyStore{revoked: make(map[int]bool), now: time.Now}
+}
+
+func (s *memoryAPIKeyStore) CreateAPIKey(ctx context.Context, key apiKey) (apiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return apiKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = len(s.keys) + 1
+	key.Scopes = slices.Clone(key.Scopes)
+	key.LastUsedAt = nil
+	key.CreatedAt = s.now().UTC()
+	s.keys = append(s.keys, key)
+
+	return key, nil
+}
+
+func (s *memoryAPIKeyStore) AuthenticateAPIKey(ctx context.Context, hash []byte) (apiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return apiKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	for i := range s.keys {
+		key := &s.keys[i]
+		if !bytes.Equal(key.Hash, hash) || s.revoked[key.ID] {
+			continue
+		}
+		if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+			return apiKey{}, errAPIKeyNotFound
+		}
+
+		if apiKeyUsed(*key, now) {
+			key.LastUsedAt = &now
+		}
+
+		return *key, nil
+	}
+
+	return apiKey{}, errAPIKeyNotFound
+}
+
+func (s *memoryAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || id > len(s.keys) || s.revoked[id] {
+		return errAPIKeyNotFound
+	}
+
+	s.revoked[id] = true
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type postgresAPIKeyStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func newPostgresAPIKeyStore(db *sql.DB) *postgresAPIKeyStore {
+	return &postgresAPIKeyStore{db: db, now: time.Now}
+}
+
+func (s *postgresAPIKeyStore) CreateAPIKey(ctx context.Context, key apiKey) (apiKey, error) {
+	key.LastUsedAt = nil
+	key.CreatedAt = s.now().UTC()
+
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		utc := key.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	err := s.db.QueryRowContext(ctx, "INSERT INTO api_keys(name, owner_id, scopes, key_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		key.Name, key.OwnerID, formatScopes(key.Scopes), key.Hash, expiresAt, key.CreatedAt).Scan(&key.ID)
+	if err != nil {
+		return apiKey{}, fmt.Errorf("exec insert into api keys: %w", err)
+	}
+	key.ExpiresAt = expiresAt
+
+	return key, nil
+}
+
+func (s *postgresAPIKeyStore) AuthenticateAPIKey(ctx context.Context, hash []byte) (apiKey, error) {
+	now := s.now().UTC()
+
+	var key apiKey
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT id, name, owner_id, scopes, key_hash, expires_at, last_used_at, created_at FROM api_keys WHERE key_hash=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)", hash, now).
+		Scan(&key.ID, &key.Name, &key.OwnerID, &scopes, &key.Hash, &expiresAt, &lastUsedAt, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiKey{}, errAPIKeyNotFound
+	}
+	if err != nil {
+		return apiKey{}, fmt.Errorf("query api key: %w", err)
+	}
+
+	key.Scopes = parseScopes(scopes)
+	key.CreatedAt = key.CreatedAt.UTC()
+	if expiresAt.Valid {
+		at := expiresAt.Time.UTC()
+		key.ExpiresAt = &at
+	}
+	if lastUsedAt.Valid {
+		at := lastUsedAt.Time.UTC()
+		key.LastUsedAt = &at
+	}
+
+	if apiKeyUsed(key, now) {
+		_, err = s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at=$1 WHERE id=$2", now, key.ID)
+		if err != nil {
+			return apiKey{}, fmt.Errorf("exec update api key last used: %w", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+func (s *postgresAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at=$1 WHERE id=$2 AND revoked_at IS NULL", s.now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("exec revoke api key: %w", err)
+	}
+
+	numRowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected on revoke api key: %w", err)
+	}
+	if numRowsAffected == 0 {
+		return errAPIKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type apiKeyStoreFactory func(t *testing.T, now func() time.Time) APIKeyStore
+
+func apiKeyStoreBackends() map[string]apiKeyStoreFactory {
+	return map[string]apiKeyStoreFactory{
+		storageBackendMemory: func(t *testing.T, now func() time.Time) APIKeyStore {
+			return &memoryAPIKeyStore{revoked: make(map[int]bool), now: now}
+		},
+		storageBackendPostgres: func(t *testing.T, now func() time.Time) APIKeyStore {
+			return &postgresAPIKeyStore{db: openTestDB(t), now: now}
+		},
+	}
+}
+
+func TestAPIKeyStore_Authenticate(t *testing.T) {
+	expiresAt := testCreatedAt(3)
+
+	for backend, newStore := range apiKeyStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			now := testCreatedAt(1)
+			store := newStore(t, func() time.Time { return now })
+
+			created, err := store.CreateAPIKey(ctx, apiKey{Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead, scopeCardsWrite}, Hash: hashAPIKey("ck_batch")})
+			require.NoError(t, err)
+			assert.Equal(t, apiKey{ID: 1, Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead, scopeCardsWrite}, Hash: hashAPIKey("ck_batch"), CreatedAt: testCreatedAt(1)}, created)
+
+			expiring, err := store.CreateAPIKey(ctx, apiKey{Name: "report", OwnerID: "svc", Scopes: []string{scopeCardsAdmin}, Hash: hashAPIKey("ck_report"), ExpiresAt: &expiresAt})
+			require.NoError(t, err)
+			assert.Equal(t, 2, expiring.ID)
+
+			_, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_unknown"))
+			assert.ErrorIs(t, err, errAPIKeyNotFound)
+
+			key, err := store.AuthenticateAPIKey(ctx, hashAPIKey("ck_batch"))
+			require.NoError(t, err)
+			assert.Equal(t, "svc", key.OwnerID)
+			assert.Equal(t, []string{scopeCardsRead, scopeCardsWrite}, key.Scopes)
+			require.NotNil(t, key.LastUsedAt)
+			assert.Equal(t, testCreatedAt(1), *key.LastUsedAt)
+
+			now = now.Add(apiKeyLastUsedResolution / 2)
+			key, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_batch"))
+			require.NoError(t, err)
+			assert.Equal(t, testCreatedAt(1), *key.LastUsedAt)
+
+			now = testCreatedAt(2)
+			key, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_batch"))
+			require.NoError(t, err)
+			assert.Equal(t, testCreatedAt(2), *key.LastUsedAt)
+
+			key, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_report"))
+			require.NoError(t, err)
+			assert.Equal(t, &expiresAt, key.ExpiresAt)
+
+			now = testCreatedAt(3)
+			_, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_report"))
+			assert.ErrorIs(t, err, errAPIKeyNotFound)
+
+			require.NoError(t, store.RevokeAPIKey(ctx, 1))
+			_, err = store.AuthenticateAPIKey(ctx, hashAPIKey("ck_batch"))
+			assert.ErrorIs(t, err, errAPIKeyNotFound)
+
+			assert.ErrorIs(t, store.RevokeAPIKey(ctx, 1), errAPIKeyNotFound)
+			assert.ErrorIs(t, store.RevokeAPIKey(ctx, 42), errAPIKeyNotFound)
+		})
+	}
+}
+
+func Test_newAPIKeySecret(t *testing.T) {
+	first, err := newAPIKeySecret()
+	require.NoError(t, err)
+	second, err := newAPIKeySecret()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first, apiKeyPrefix))
+	assert.Len(t, first, len(apiKeyPrefix)+43)
+	assert.NotEqual(t, first, second)
+	assert.NotEqual(t, hashAPIKey(first), hashAPIKey(second))
+}
+
+func Test_validateAPIKeyRequest(t *testing.T) {
+	now := testCreatedAt(1)
+	future := testCreatedAt(2)
+	past := testCreatedAt(0)
+
+	testCases := map[string]struct {
+		req       apiKeyRequest
+		expErrors map[string]string
+	}{
+		"valid": {
+			req: apiKeyRequest{Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead}, ExpiresAt: &future},
+		},
+		"missing_fields": {
+			req: apiKeyRequest{},
+			expErrors: map[string]string{
+				"name":     "cannot be blank",
+				"owner_id": "cannot be blank",
+				"scopes":   "cannot be blank",
+			},
+		},
+		"unknown_scope": {
+			req:       apiKeyRequest{Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead, "cards:delete"}},
+			expErrors: map[string]string{"scopes": `unknown scope "cards:delete"`},
+		},
+		"expired": {
+			req:       apiKeyRequest{Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead}, ExpiresAt: &past},
+			expErrors: map[string]string{"expires_at": "must be in the future"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateAPIKeyRequest(languageEnglish, tc.req, now)
+			if tc.expErrors == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErrors validation.Errors
+			require.ErrorAs(t, err, &validationErrors)
+
+			got := make(map[string]string, len(validationErrors))
+			for field, fieldErr := range validationErrors {
+				got[field] = fieldErr.Error()
+			}
+			assert.Equal(t, tc.expErrors, got)
+		})
+	}
+}
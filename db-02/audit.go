@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	auditActionCreate  = "create"
+	auditActionUpdate  = "update"
+	auditActionDelete  = "delete"
+	auditActionRestore = "restore"
+	auditActionReveal  = "reveal"
+)
+
+type auditCardSnapshot struct {
+	Number         string     `json:"number"`
+	ExpirationDate string     `json:"expiration_date"`
+	Holder         string     `json:"holder"`
+	Version        int        `json:"version"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+type auditEntry struct {
+	ID          int                `json:"id"`
+	CardID      int                `json:"card_id"`
+	OwnerID     string             `json:"-"`
+	Action      string             `json:"action"`
+	Before      *auditCardSnapshot `json:"before"`
+	After       *auditCardSnapshot `json:"after"`
+	Actor       string             `json:"actor"`
+	CountryCode string             `json:"country_code"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type AuditLog interface {
+	RecordAudit(ctx context.Context, entry auditEntry) (auditEntry, error)
+	ListAudit(ctx context.Context, owner string, cardID int) ([]auditEntry, error)
+}
+
+func auditSnapshot(card *creditCard) *auditCardSnapshot {
+	if card == nil {
+		return nil
+	}
+
+	return &auditCardSnapshot{
+		Number:         maskPAN(card.Number),
+		ExpirationDate: card.ExpirationDate.String(),
+		Holder:         card.Holder,
+		Version:        card.Version,
+		DeletedAt:      card.DeletedAt,
+	}
+}
+
+func requestActor(r *http.Request) string {
+	caller := requestPrincipal(r)
+	if caller.APIKeyID != 0 {
+		return "api-key:" + strconv.Itoa(caller.APIKeyID)
+	}
+	if caller.Subject != "" {
+		return "user:" + caller.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+func requestAuditEntry(r *http.Request) auditEntry {
+	return auditEntry{
+		OwnerID:     requestSubject(r),
+		Actor:       requestActor(r),
+		CountryCode: requestCountry(r),
+	}
+}
+
+func cardAuditEntry(entry auditEntry, action string, before, after *creditCard) auditEntry {
+	card := after
+	if card == nil {
+		card = before
+	}
+
+	entry.CardID = card.ID
+	entry.OwnerID = card.OwnerID
+	entry.Action = action
+	entry.Before = auditSnapshot(before)
+	entry.After = auditSnapshot(after)
+	return entry
+}
+
+func recordAudit(r *http.Request, audit AuditLog, action string, cardID int) error {
+	entry := requestAuditEntry(r)
+	entry.CardID = cardID
+	entry.Action = action
+
+	_, err := audit.RecordAudit(context.WithoutCancel(r.Context()), entry)
+	if err != nil {
+		return fmt.Errorf("record %s audit for credit card %d: %w", action, cardID, err)
+	}
+
+	return nil
+}
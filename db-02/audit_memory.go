@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryAuditLog struct {
+	mu      sync.RWMutex
+	entries []auditEntry
+	now     func() time.Time
+}
+
+func newMemoryAuditLog() *memoryAuditLog {
+	return &memoryAuditLog{now: time.Now}
+}
+
+func (l *memoryAuditLog) RecordAudit(ctx context.Context, entry auditEntry) (auditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return auditEntry{}, err
+	}
+
+	return l.record(entry), nil
+}
+
+func (l *memoryAuditLog) record(entry auditEntry) auditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = len(l.entries) + 1
+	entry.CreatedAt = l.now().UTC()
+	l.entries = append(l.entries, entry)
+
+	return entry
+}
+
+func (l *memoryAuditLog) ListAudit(ctx context.Context, owner string, cardID int) ([]auditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	entries := make([]auditEntry, 0)
+	for _, entry := range l.entries {
+		if entry.CardID == cardID && entry.OwnerID == owner {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
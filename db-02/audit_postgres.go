@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type postgresAuditLog struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func newPostgresAuditLog(db *sql.DB) *postgresAuditLog {
+	return &postgresAuditLog{db: db, now: time.Now}
+}
+
+func (l *postgresAuditLog) RecordAudit(ctx context.Context, entry auditEntry) (auditEntry, error) {
+	return l.record(ctx, l.db, entry)
+}
+
+func (l *postgresAuditLog) record(ctx context.Context, q sqlQuerier, entry auditEntry) (auditEntry, error) {
+	before, err := marshalAuditSnapshot(entry.Before)
+	if err != nil {
+		return auditEntry{}, err
+	}
+
+	after, err := marshalAuditSnapshot(entry.After)
+	if err != nil {
+		return auditEntry{}, err
+	}
+
+	entry.CreatedAt = l.now().UTC()
+
+	err = q.QueryRowContext(ctx, "INSERT INTO credit_card_audit_log(card_id, owner_id, action, before, after, actor, country_code, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		entry.CardID, entry.OwnerID, entry.Action, before, after, entry.Actor, entry.CountryCode, entry.CreatedAt).Scan(&entry.ID)
+	if err != nil {
+		return auditEntry{}, fmt.Errorf("exec insert into credit card audit log: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (l *postgresAuditLog) ListAudit(ctx context.Context, owner string, cardID int) ([]auditEntry, error) {
+	rows, err := l.db.QueryContext(ctx, "SELECT id, card_id, owner_id, action, before, after, actor, country_code, created_at FROM credit_card_audit_log WHERE card_id=$1 AND owner_id=$2 ORDER BY id", cardID, owner)
+	if err != nil {
+		return nil, fmt.Errorf("query credit card audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]auditEntry, 0)
+	for rows.Next() {
+		var entry auditEntry
+		var before, after sql.NullString
+		err := rows.Scan(&entry.ID, &entry.CardID, &entry.OwnerID, &entry.Action, &before, &after, &entry.Actor, &entry.CountryCode, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan credit card audit entry: %w", err)
+		}
+
+		if entry.Before, err = unmarshalAuditSnapshot(before); err != nil {
+			return nil, err
+		}
+		if entry.After, err = unmarshalAuditSnapshot(after); err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = entry.CreatedAt.UTC()
+
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate credit card audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+func marshalAuditSnapshot(snapshot *auditCardSnapshot) (sql.NullString, error) {
+	if snapshot == nil {
+		return sql.NullString{}, nil
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("marshal audit snapshot: %w", err)
+	}
+
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+func unmarshalAuditSnapshot(raw sql.NullString) (*auditCardSnapshot, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+
+	var snapshot auditCardSnapshot
+	if err := json.Unmarshal([]byte(raw.String), &snapshot); err != nil {
+		return nil, fmt.Errorf("unmarshal audit snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
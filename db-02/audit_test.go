@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditLogFactory func(t *testing.T) AuditLog
+
+func auditLogBackends() map[string]auditLogFactory {
+	return map[string]auditLogFactory{
+		storageBackendMemory: func(t *testing.T) AuditLog {
+			return &memoryAuditLog{now: newTestClock()}
+		},
+		storageBackendPostgres: func(t *testing.T) AuditLog {
+			return &postgresAuditLog{db: openTestDB(t), now: newTestClock()}
+		},
+	}
+}
+
+func TestAuditLog_RecordAndList(t *testing.T) {
+	deletedAt := time.Date(2024, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	card := creditCard{
+		ID:             1,
+		Number:         "4263982640269299",
+		ExpirationDate: testExpirationDate("12/43"),
+		Holder:         "Іванко",
+		Version:        1,
+	}
+	updated := card
+	updated.Holder = "Іванко Петренко"
+	updated.Version = 2
+	deleted := updated
+	deleted.Version = 3
+	deleted.DeletedAt = &deletedAt
+
+	entries := []auditEntry{
+		{CardID: 1, Action: auditActionCreate, After: auditSnapshot(&card), Actor: "ip:203.0.113.7", CountryCode: uaCountryCode},
+		{CardID: 2, Action: auditActionCreate, After: auditSnapshot(&creditCard{ID: 2}), Actor: "ip:203.0.113.8", CountryCode: usCountryCode},
+		{CardID: 1, Action: auditActionUpdate, Before: auditSnapshot(&card), After: auditSnapshot(&updated), Actor: "ip:203.0.113.7", CountryCode: uaCountryCode},
+		{CardID: 1, Action: auditActionDelete, Before: auditSnapshot(&updated), After: auditSnapshot(&deleted), Actor: "ip:203.0.113.9", CountryCode: gbCountryCode},
+		{CardID: 1, OwnerID: "bob", Action: auditActionCreate, After: auditSnapshot(&card), Actor: "user:bob", CountryCode: uaCountryCode},
+	}
+
+	expEntries := []auditEntry{
+		{
+			ID:          1,
+			CardID:      1,
+			Action:      auditActionCreate,
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(1),
+		},
+		{
+			ID:          3,
+			CardID:      1,
+			Action:      auditActionUpdate,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко Петренко", Version: 2},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(3),
+		},
+		{
+			ID:          4,
+			CardID:      1,
+			Action:      auditActionDelete,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко Петренко", Version: 2},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко Петренко", Version: 3, DeletedAt: &deletedAt},
+			Actor:       "ip:203.0.113.9",
+			CountryCode: gbCountryCode,
+			CreatedAt:   testCreatedAt(4),
+		},
+	}
+
+	for backend, newAuditLog := range auditLogBackends() {
+		t.Run(backend, func(t *testing.T) {
+			audit := newAuditLog(t)
+
+			for _, entry := range entries {
+				_, err := audit.RecordAudit(context.Background(), entry)
+				require.NoError(t, err)
+			}
+
+			got, err := audit.ListAudit(context.Background(), "", 1)
+			require.NoError(t, err)
+			assert.Equal(t, expEntries, got)
+
+			got, err = audit.ListAudit(context.Background(), "", 42)
+			require.NoError(t, err)
+			assert.Empty(t, got)
+
+			got, err = audit.ListAudit(context.Background(), "bob", 1)
+			require.NoError(t, err)
+			require.Len(t, got, 1)
+			assert.Equal(t, 5, got[0].ID)
+			assert.Equal(t, "user:bob", got[0].Actor)
+		})
+	}
+}
+
+func cardStoreAuditLog(store CardStore) AuditLog {
+	switch store := store.(type) {
+	case *memoryCardStore:
+		return store.audit
+	case *postgresCardStore:
+		return store.audit
+	}
+	return nil
+}
+
+func TestCardStore_RecordsAudit(t *testing.T) {
+	request := auditEntry{Actor: "ip:203.0.113.7", CountryCode: uaCountryCode}
+	deletedAt := testCreatedAt(2)
+
+	expEntries := []auditEntry{
+		{
+			ID:          1,
+			CardID:      1,
+			Action:      auditActionCreate,
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(1),
+		},
+		{
+			ID:          2,
+			CardID:      1,
+			Action:      auditActionUpdate,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко Петренко", Version: 2},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(2),
+		},
+		{
+			ID:          3,
+			CardID:      1,
+			Action:      auditActionUpdate,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко Петренко", Version: 2},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "01/44", Holder: "Іванко Петренко", Version: 3},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(3),
+		},
+		{
+			ID:          4,
+			CardID:      1,
+			Action:      auditActionDelete,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "01/44", Holder: "Іванко Петренко", Version: 3},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "01/44", Holder: "Іванко Петренко", Version: 4, DeletedAt: &deletedAt},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(4),
+		},
+		{
+			ID:          5,
+			CardID:      1,
+			Action:      auditActionRestore,
+			Before:      &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "01/44", Holder: "Іванко Петренко", Version: 4, DeletedAt: &deletedAt},
+			After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "01/44", Holder: "Іванко Петренко", Version: 5},
+			Actor:       "ip:203.0.113.7",
+			CountryCode: uaCountryCode,
+			CreatedAt:   testCreatedAt(5),
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			card, err := store.SaveCard(ctx, creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}, request)
+			require.NoError(t, err)
+
+			card.Holder = "Іванко Петренко"
+			_, err = store.UpdateCard(ctx, card, request)
+			require.NoError(t, err)
+
+			expiration := testExpirationDate("01/44")
+			_, err = store.PatchCard(ctx, "", card.ID, 0, cardPatch{ExpirationDate: &expiration}, request)
+			require.NoError(t, err)
+
+			require.NoError(t, store.DeleteCard(ctx, "", card.ID, request))
+
+			_, err = store.RestoreCard(ctx, "", card.ID, request)
+			require.NoError(t, err)
+
+			_, err = store.PatchCard(ctx, "", 42, 0, cardPatch{ExpirationDate: &expiration}, request)
+			require.ErrorIs(t, err, errCreditCardNotFound)
+
+			got, err := cardStoreAuditLog(store).ListAudit(ctx, "", card.ID)
+			require.NoError(t, err)
+			assert.Equal(t, expEntries, got)
+		})
+	}
+}
+
+func TestPostgresCardStore_AuditFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store := &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+
+	seedCards(t, store, []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"},
+		{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро"},
+	})
+	require.NoError(t, store.DeleteCard(ctx, "", 2, auditEntry{}))
+	expPage, err := store.ListCards(ctx, listCardsQuery{IncludeDeleted: true})
+	require.NoError(t, err)
+
+	_, err = db.Exec("DROP TABLE credit_card_audit_log")
+	require.NoError(t, err)
+
+	holder := "Іванко Петренко"
+	_, err = store.SaveCard(ctx, creditCard{Number: "378282246310005", ExpirationDate: testExpirationDate("02/31"), Holder: "Оксана"}, auditEntry{})
+	assert.Error(t, err)
+	_, err = store.UpdateCard(ctx, creditCard{ID: 1, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: holder}, auditEntry{})
+	assert.Error(t, err)
+	_, err = store.PatchCard(ctx, "", 1, 0, cardPatch{Holder: &holder}, auditEntry{})
+	assert.Error(t, err)
+	assert.Error(t, store.DeleteCard(ctx, "", 1, auditEntry{}))
+	_, err = store.RestoreCard(ctx, "", 2, auditEntry{})
+	assert.Error(t, err)
+
+	page, err := store.ListCards(ctx, listCardsQuery{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, expPage, page)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const authorizationHeaderKey = "Authorization"
+
+const tokenLeeway = 30 * time.Second
+
+const minHMACKeySize = 32
+
+const (
+	scopeCardsRead   = "cards:read"
+	scopeCardsWrite  = "cards:write"
+	scopeCardsReveal = "cards:reveal"
+	scopeCardsAdmin  = "cards:admin"
+)
+
+var knownScopes = []string{scopeCardsRead, scopeCardsWrite, scopeCardsReveal, scopeCardsAdmin}
+
+var defaultUserScopes = []string{scopeCardsRead, scopeCardsWrite}
+
+var (
+	errMissingBearerToken = errors.New("missing bearer token")
+	errInvalidBearerToken = errors.New("invalid bearer token")
+)
+
+type tokenKeysFile struct {
+	Keys []tokenKeyEntry `json:"keys"`
+}
+
+type tokenKeyEntry struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret"`
+	PublicKey string `json:"public_key"`
+}
+
+type verificationKey struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+type principal struct {
+	Subject  string
+	APIKeyID int
+	Scopes   []string
+}
+
+func (p principal) hasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type tokenVerifier struct {
+	keys     map[string]verificationKey
+	issuer   string
+	audience string
+}
+
+func loadTokenVerifier(path, issuer, audience string) (*tokenVerifier, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read token keys: %w", err)
+	}
+
+	var file tokenKeysFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("decode token keys: %w", err)
+	}
+
+	keys := make(map[string]verificationKey, len(file.Keys))
+	for _, entry := range file.Keys {
+		if _, ok := keys[entry.ID]; ok {
+			return nil, fmt.Errorf("duplicate token key %q", entry.ID)
+		}
+
+		key, err := parseVerificationKey(entry.Algorithm, entry.Secret, entry.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("token key %q: %w", entry.ID, err)
+		}
+		keys[entry.ID] = key
+	}
+
+	return newTokenVerifier(keys, issuer, audience)
+}
+
+func newTokenVerifier(keys map[string]verificationKey, issuer, audience string) (*tokenVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one token key is required")
+	}
+	if _, ok := keys[""]; ok && len(keys) > 1 {
+		return nil, errors.New("token key id must not be empty when several keys are configured")
+	}
+
+	return &tokenVerifier{keys: keys, issuer: issuer, audience: audience}, nil
+}
+
+func parseVerificationKey(algorithm, secret, publicKey string) (verificationKey, error) {
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		material, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("decode secret: %w", err)
+		}
+		if len(material) < minHMACKeySize {
+			return verificationKey{}, fmt.Errorf("secret must be at least %d bytes, got %d", minHMACKeySize, len(material))
+		}
+
+		return verificationKey{algorithm: algorithm, key: material}, nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		block, _ := pem.Decode([]byte(publicKey))
+		if block == nil {
+			return verificationKey{}, errors.New("public key must be PEM encoded")
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("parse public key: %w", err)
+		}
+
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if algorithm != jwt.SigningMethodRS256.Alg() {
+				return verificationKey{}, fmt.Errorf("RSA public key cannot verify %s", algorithm)
+			}
+		case ed25519.PublicKey:
+			if algorithm != jwt.SigningMethodEdDSA.Alg() {
+				return verificationKey{}, fmt.Errorf("Ed25519 public key cannot verify %s", algorithm)
+			}
+		default:
+			return verificationKey{}, fmt.Errorf("unsupported public key type %T", key)
+		}
+
+		return verificationKey{algorithm: algorithm, key: key}, nil
+	}
+
+	return verificationKey{}, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+func (v *tokenVerifier) verify(token string) (principal, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, v.keyFunc, options...)
+	if err != nil {
+		return principal{}, fmt.Errorf("%w: %w", errInvalidBearerToken, err)
+	}
+	if claims.Subject == "" {
+		return principal{}, fmt.Errorf("%w: subject is required", errInvalidBearerToken)
+	}
+
+	scopes := parseScopes(claims.Scope)
+	if len(scopes) == 0 {
+		scopes = defaultUserScopes
+	}
+
+	return principal{Subject: claims.Subject, Scopes: scopes}, nil
+}
+
+func (v *tokenVerifier) keyFunc(token *jwt.Token) (any, error) {
+	keyID, _ := token.Header["kid"].(string)
+
+	key, ok := v.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("key %q does not verify %s", keyID, token.Method.Alg())
+	}
+
+	return key.key, nil
+}
+
+func bearerToken(header http.Header) (string, error) {
+	scheme, token, ok := strings.Cut(header.Get(authorizationHeaderKey), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", errMissingBearerToken
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+type principalContextKey struct{}
+
+func authMiddleware(verifier *tokenVerifier, apiKeys APIKeyStore, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(xAPIKeyHeaderKey) != "" {
+			caller, err := authenticateAPIKey(r.Context(), apiKeys, r.Header)
+			if errors.Is(err, errAPIKeyNotFound) {
+				writeProblem(w, r, problemUnauthorized, localize(r, msgAPIKeyInvalid))
+				return
+			}
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, withPrincipal(r, caller))
+			return
+		}
+
+		token, err := bearerToken(r.Header)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			writeProblem(w, r, problemUnauthorized, localize(r, msgBearerTokenRequired))
+			return
+		}
+
+		caller, err := verifier.verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeProblem(w, r, problemUnauthorized, localize(r, msgBearerTokenInvalid))
+			return
+		}
+
+		next.ServeHTTP(w, withPrincipal(r, caller))
+	}
+}
+
+func requireScopeMiddleware(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requestPrincipal(r).hasScope(scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+			writeProblem(w, r, problemForbidden, localize(r, msgScopeRequired, scope))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func withPrincipal(r *http.Request, caller principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalContextKey{}, caller))
+}
+
+func requestPrincipal(r *http.Request) principal {
+	caller, _ := r.Context().Value(principalContextKey{}).(principal)
+	return caller
+}
+
+func requestSubject(r *http.Request) string {
+	return requestPrincipal(r).Subject
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSigningKeys struct {
+	secret     []byte
+	rsaKey     *rsa.PrivateKey
+	ed25519Key ed25519.PrivateKey
+}
+
+func newTestSigningKeys(t *testing.T) testSigningKeys {
+	t.Helper()
+
+	secret := make([]byte, minHMACKeySize)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return testSigningKeys{secret: secret, rsaKey: rsaKey, ed25519Key: ed25519Key}
+}
+
+func (k testSigningKeys) writeKeysFile(t *testing.T) string {
+	t.Helper()
+
+	file := tokenKeysFile{Keys: []tokenKeyEntry{
+		{ID: "hs", Algorithm: "HS256", Secret: base64.StdEncoding.EncodeToString(k.secret)},
+		{ID: "rs", Algorithm: "RS256", PublicKey: testPublicKeyPEM(t, k.rsaKey.Public())},
+		{ID: "ed", Algorithm: "EdDSA", PublicKey: testPublicKeyPEM(t, k.ed25519Key.Public())},
+	}}
+
+	raw, err := json.Marshal(file)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwt-keys.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	return path
+}
+
+func testPublicKeyPEM(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func testToken(t *testing.T, method jwt.SigningMethod, keyID string, key any, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func testClaims(subject string, expiresIn time.Duration) tokenClaims {
+	return tokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "cards-issuer",
+		Audience:  jwt.ClaimStrings{"cards-api"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}}
+}
+
+func Test_tokenVerifier(t *testing.T) {
+	keys := newTestSigningKeys(t)
+	verifier, err := loadTokenVerifier(keys.writeKeysFile(t), "cards-issuer", "cards-api")
+	require.NoError(t, err)
+
+	wrongIssuer := testClaims("alice", time.Hour)
+	wrongIssuer.Issuer = "someone-else"
+	wrongAudience := testClaims("alice", time.Hour)
+	wrongAudience.Audience = jwt.ClaimStrings{"other-api"}
+	noExpiration := testClaims("alice", time.Hour)
+	noExpiration.ExpiresAt = nil
+	scoped := testClaims("dave", time.Hour)
+	scoped.Scope = "cards:read cards:reveal"
+
+	testCases := map[string]struct {
+		token     string
+		expCaller principal
+		expErr    error
+	}{
+		"hs256": {
+			token:     testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, testClaims("alice", time.Hour)),
+			expCaller: principal{Subject: "alice", Scopes: defaultUserScopes},
+		},
+		"rs256": {
+			token:     testToken(t, jwt.SigningMethodRS256, "rs", keys.rsaKey, testClaims("bob", time.Hour)),
+			expCaller: principal{Subject: "bob", Scopes: defaultUserScopes},
+		},
+		"eddsa": {
+			token:     testToken(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519Key, testClaims("carol", time.Hour)),
+			expCaller: principal{Subject: "carol", Scopes: defaultUserScopes},
+		},
+		"scope_claim": {
+			token:     testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, scoped),
+			expCaller: principal{Subject: "dave", Scopes: []string{scopeCardsRead, scopeCardsReveal}},
+		},
+		"within_leeway": {
+			token:     testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, testClaims("alice", -tokenLeeway/2)),
+			expCaller: principal{Subject: "alice", Scopes: defaultUserScopes},
+		},
+		"expired": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, testClaims("alice", -time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"missing_expiration": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, noExpiration),
+			expErr: errInvalidBearerToken,
+		},
+		"missing_subject": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, testClaims("", time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"wrong_issuer": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, wrongIssuer),
+			expErr: errInvalidBearerToken,
+		},
+		"wrong_audience": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, wrongAudience),
+			expErr: errInvalidBearerToken,
+		},
+		"unknown_key_id": {
+			token:  testToken(t, jwt.SigningMethodHS256, "missing", keys.secret, testClaims("alice", time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"algorithm_does_not_match_key": {
+			token:  testToken(t, jwt.SigningMethodHS256, "rs", []byte(testPublicKeyPEM(t, keys.rsaKey.Public())), testClaims("alice", time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"wrong_signature": {
+			token:  testToken(t, jwt.SigningMethodHS256, "hs", []byte("another-secret-that-is-long-enough"), testClaims("alice", time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"unsigned": {
+			token:  testToken(t, jwt.SigningMethodNone, "hs", jwt.UnsafeAllowNoneSignatureType, testClaims("alice", time.Hour)),
+			expErr: errInvalidBearerToken,
+		},
+		"malformed": {
+			token:  "not-a-token",
+			expErr: errInvalidBearerToken,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			caller, err := verifier.verify(tc.token)
+			assert.ErrorIs(t, err, tc.expErr)
+			assert.Equal(t, tc.expCaller, caller)
+		})
+	}
+}
+
+func Test_parseVerificationKey(t *testing.T) {
+	keys := newTestSigningKeys(t)
+
+	testCases := map[string]struct {
+		algorithm string
+		secret    string
+		publicKey string
+		expErr    bool
+	}{
+		"hs256":                 {algorithm: "HS256", secret: base64.StdEncoding.EncodeToString(keys.secret)},
+		"hs256_short_secret":    {algorithm: "HS256", secret: base64.StdEncoding.EncodeToString([]byte("short")), expErr: true},
+		"hs256_invalid_base64":  {algorithm: "HS256", secret: "%%%", expErr: true},
+		"rs256":                 {algorithm: "RS256", publicKey: testPublicKeyPEM(t, keys.rsaKey.Public())},
+		"rs256_with_ed25519":    {algorithm: "RS256", publicKey: testPublicKeyPEM(t, keys.ed25519Key.Public()), expErr: true},
+		"eddsa":                 {algorithm: "EdDSA", publicKey: testPublicKeyPEM(t, keys.ed25519Key.Public())},
+		"eddsa_with_rsa":        {algorithm: "EdDSA", publicKey: testPublicKeyPEM(t, keys.rsaKey.Public()), expErr: true},
+		"public_key_not_pem":    {algorithm: "RS256", publicKey: "public key", expErr: true},
+		"unsupported_algorithm": {algorithm: "HS512", secret: base64.StdEncoding.EncodeToString(keys.secret), expErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			key, err := parseVerificationKey(tc.algorithm, tc.secret, tc.publicKey)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.algorithm, key.algorithm)
+		})
+	}
+}
+
+func Test_authMiddleware(t *testing.T) {
+	keys := newTestSigningKeys(t)
+	verifier, err := loadTokenVerifier(keys.writeKeysFile(t), "", "")
+	require.NoError(t, err)
+
+	apiKeys := newMemoryAPIKeyStore()
+	_, err = apiKeys.CreateAPIKey(context.Background(), apiKey{Name: "batch", OwnerID: "batch-owner", Scopes: []string{scopeCardsRead}, Hash: hashAPIKey("ck_valid")})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		authorization      string
+		apiKey             string
+		expStatusCode      int
+		expWWWAuthenticate string
+		expBody            string
+	}{
+		"missing_token": {
+			expStatusCode:      http.StatusUnauthorized,
+			expWWWAuthenticate: "Bearer",
+			expBody:            testProblem(problemUnauthorized, englishMessages[msgBearerTokenRequired], nil),
+		},
+		"wrong_scheme": {
+			authorization:      "Basic YWxpY2U6c2VjcmV0",
+			expStatusCode:      http.StatusUnauthorized,
+			expWWWAuthenticate: "Bearer",
+			expBody:            testProblem(problemUnauthorized, englishMessages[msgBearerTokenRequired], nil),
+		},
+		"invalid_token": {
+			authorization:      "Bearer " + testToken(t, jwt.SigningMethodHS256, "hs", keys.secret, testClaims("alice", -time.Hour)),
+			expStatusCode:      http.StatusUnauthorized,
+			expWWWAuthenticate: `Bearer error="invalid_token"`,
+			expBody:            testProblem(problemUnauthorized, englishMessages[msgBearerTokenInvalid], nil),
+		},
+		"valid_token": {
+			authorization: "Bearer " + testToken(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519Key, testClaims("alice", time.Hour)),
+			expStatusCode: http.StatusOK,
+			expBody:       "alice",
+		},
+		"valid_api_key": {
+			apiKey:        "ck_valid",
+			expStatusCode: http.StatusOK,
+			expBody:       "batch-owner",
+		},
+		"api_key_takes_precedence": {
+			authorization: "Bearer " + testToken(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519Key, testClaims("alice", time.Hour)),
+			apiKey:        "ck_valid",
+			expStatusCode: http.StatusOK,
+			expBody:       "batch-owner",
+		},
+		"unknown_api_key": {
+			apiKey:        "ck_unknown",
+			expStatusCode: http.StatusUnauthorized,
+			expBody:       testProblem(problemUnauthorized, englishMessages[msgAPIKeyInvalid], nil),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			if tc.authorization != "" {
+				request.Header.Set(authorizationHeaderKey, tc.authorization)
+			}
+			if tc.apiKey != "" {
+				request.Header.Set(xAPIKeyHeaderKey, tc.apiKey)
+			}
+
+			rw := httptest.NewRecorder()
+			authMiddleware(verifier, apiKeys, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(requestSubject(r)))
+			})(rw, request)
+
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expWWWAuthenticate, rw.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, tc.expBody, rw.Body.String())
+		})
+	}
+}
+
+func Test_requireScopeMiddleware(t *testing.T) {
+	testCases := map[string]struct {
+		scopes             []string
+		expStatusCode      int
+		expWWWAuthenticate string
+		expBody            string
+	}{
+		"has_scope": {
+			scopes:        []string{scopeCardsRead, scopeCardsReveal},
+			expStatusCode: http.StatusOK,
+		},
+		"missing_scope": {
+			scopes:             defaultUserScopes,
+			expStatusCode:      http.StatusForbidden,
+			expWWWAuthenticate: `Bearer error="insufficient_scope", scope="cards:reveal"`,
+			expBody:            testProblem(problemForbidden, "the cards:reveal scope is required", nil),
+		},
+		"unauthenticated": {
+			expStatusCode:      http.StatusForbidden,
+			expWWWAuthenticate: `Bearer error="insufficient_scope", scope="cards:reveal"`,
+			expBody:            testProblem(problemForbidden, "the cards:reveal scope is required", nil),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/cards/1/reveal", nil)
+			if tc.scopes != nil {
+				request = withPrincipal(request, principal{Subject: "alice", Scopes: tc.scopes})
+			}
+
+			rw := httptest.NewRecorder()
+			requireScopeMiddleware(scopeCardsReveal, func(w http.ResponseWriter, r *http.Request) {})(rw, request)
+
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expWWWAuthenticate, rw.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, tc.expBody, rw.Body.String())
+		})
+	}
+}
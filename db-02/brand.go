@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	brandVisa       = "visa"
+	brandMastercard = "mastercard"
+	brandAmex       = "amex"
+	brandProstir    = "prostir"
+	brandDiscover   = "discover"
+	brandUnknown    = "unknown"
+)
+
+//go:embed data/bin_ranges.csv
+var bundledBINRanges []byte
+
+type binRange struct {
+	low   string
+	high  string
+	brand string
+}
+
+type binTable struct {
+	ranges []binRange
+}
+
+func loadBINTable(path string) (*binTable, error) {
+	if path == "" {
+		return parseBINTable(bytes.NewReader(bundledBINRanges))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bin table: %w", err)
+	}
+	defer f.Close()
+
+	return parseBINTable(f)
+}
+
+func parseBINTable(r io.Reader) (*binTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read bin table header: %w", err)
+	}
+	if !slices.Equal(header, []string{"low", "high", "brand"}) {
+		return nil, fmt.Errorf("bin table header must be low,high,brand, got %s", strings.Join(header, ","))
+	}
+
+	table := &binTable{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bin table: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		entry := binRange{low: record[0], high: record[1], brand: strings.ToLower(record[2])}
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("bin table line %d: %w", line, err)
+		}
+
+		table.ranges = append(table.ranges, entry)
+	}
+
+	slices.SortStableFunc(table.ranges, func(a, b binRange) int {
+		return len(b.low) - len(a.low)
+	})
+
+	return table, nil
+}
+
+func (r binRange) validate() error {
+	if r.low == "" || !isDigits(r.low) || !isDigits(r.high) {
+		return fmt.Errorf("prefixes %q and %q must be digits", r.low, r.high)
+	}
+	if len(r.low) != len(r.high) {
+		return fmt.Errorf("prefixes %q and %q must have the same length", r.low, r.high)
+	}
+	if r.low > r.high {
+		return fmt.Errorf("prefix %q is greater than %q", r.low, r.high)
+	}
+	if r.brand == "" {
+		return errors.New("brand must not be empty")
+	}
+
+	return nil
+}
+
+func (t *binTable) brand(number string) string {
+	for _, r := range t.ranges {
+		if len(number) < len(r.low) {
+			continue
+		}
+
+		prefix := number[:len(r.low)]
+		if prefix >= r.low && prefix <= r.high {
+			return r.brand
+		}
+	}
+
+	return brandUnknown
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+type brandPolicy struct {
+	bins     *binTable
+	accepted []string
+}
+
+func newBrandPolicy(bins *binTable, accepted []string) *brandPolicy {
+	return &brandPolicy{bins: bins, accepted: accepted}
+}
+
+func (p *brandPolicy) detect(number string) string {
+	return p.bins.brand(number)
+}
+
+func (p *brandPolicy) accepts(brand string) bool {
+	return len(p.accepted) == 0 || slices.Contains(p.accepted, brand)
+}
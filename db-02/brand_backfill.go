@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+type brandBackfillRow struct {
+	id      int
+	version int
+	brand   string
+}
+
+func (s *postgresCardStore) RedetectBrands(ctx context.Context, brands *brandPolicy, batchSize int) (int, error) {
+	changed := 0
+	lastID := 0
+	for {
+		batch, err := s.brandBackfillBatch(ctx, brands, lastID, batchSize)
+		if err != nil {
+			return changed, err
+		}
+		if len(batch) == 0 {
+			return changed, nil
+		}
+
+		n, err := s.redetectBatch(ctx, batch)
+		changed += n
+		if err != nil {
+			return changed, err
+		}
+		s.logger.Debug("re-detected credit card brand batch", slog.Int("count", n), slog.Int("last_id", batch[len(batch)-1].id))
+
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func (s *postgresCardStore) brandBackfillBatch(ctx context.Context, brands *brandPolicy, afterID, batchSize int) ([]brandBackfillRow, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+cardColumns+" FROM credit_cards WHERE id > $1 ORDER BY id LIMIT $2", afterID, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query credit cards to re-detect brand: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []brandBackfillRow
+	for rows.Next() {
+		card, err := s.scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan credit card to re-detect brand: %w", err)
+		}
+
+		batch = append(batch, brandBackfillRow{id: card.ID, version: card.Version, brand: brands.detect(card.Number)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate credit cards to re-detect brand: %w", err)
+	}
+
+	return batch, nil
+}
+
+func (s *postgresCardStore) redetectBatch(ctx context.Context, batch []brandBackfillRow) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin brand re-detection: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed := 0
+	for _, row := range batch {
+		res, err := tx.ExecContext(ctx, "UPDATE credit_cards SET brand=$1 WHERE id=$2 AND version=$3 AND brand<>$1", row.brand, row.id, row.version)
+		if err != nil {
+			return 0, fmt.Errorf("exec re-detect brand of credit card %d: %w", row.id, err)
+		}
+
+		numRowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("rows affected on brand re-detection: %w", err)
+		}
+		changed += int(numRowsAffected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit brand re-detection: %w", err)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresCardStore_RedetectBrands(t *testing.T) {
+	testCases := map[string]struct {
+		batchSize int
+	}{
+		"single_batch":    {batchSize: 10},
+		"several_batches": {batchSize: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := openTestDB(t)
+			store := &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+			seedCards(t, store, []creditCard{
+				{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко", Brand: brandVisa},
+				{Number: "6011111111111117", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро", Brand: brandUnknown},
+				{Number: "3530111333300000", ExpirationDate: testExpirationDate("02/31"), Holder: "Оксана", Brand: brandUnknown},
+				{Number: "5018000000000009", ExpirationDate: testExpirationDate("03/32"), Holder: "Марія", Brand: brandUnknown},
+			})
+			require.NoError(t, store.DeleteCard(ctx, "", 4, auditEntry{}))
+
+			changed, err := store.RedetectBrands(ctx, testBrandPolicy(t), tc.batchSize)
+			require.NoError(t, err)
+			assert.Equal(t, 3, changed)
+
+			page, err := store.ListCards(ctx, listCardsQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			var brands []string
+			var versions []int
+			for _, card := range page.Cards {
+				brands = append(brands, card.Brand)
+				versions = append(versions, card.Version)
+			}
+			assert.Equal(t, []string{brandVisa, brandDiscover, "jcb", "maestro"}, brands)
+			assert.Equal(t, []int{1, 1, 1, 2}, versions)
+
+			changed, err = store.RedetectBrands(ctx, testBrandPolicy(t), tc.batchSize)
+			require.NoError(t, err)
+			assert.Zero(t, changed)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBrandPolicy(t *testing.T, accepted ...string) *brandPolicy {
+	t.Helper()
+
+	bins, err := loadBINTable("")
+	require.NoError(t, err)
+
+	return newBrandPolicy(bins, accepted)
+}
+
+func Test_binTableBrand(t *testing.T) {
+	testCases := map[string]struct {
+		number   string
+		expBrand string
+	}{
+		"visa":              {number: "4263982640269299", expBrand: brandVisa},
+		"mastercard_51_55":  {number: "5555555555554444", expBrand: brandMastercard},
+		"mastercard_2_bins": {number: "2223003122003222", expBrand: brandMastercard},
+		"amex":              {number: "378282246310005", expBrand: brandAmex},
+		"prostir":           {number: "9804000000000000", expBrand: brandProstir},
+		"discover":          {number: "6011111111111117", expBrand: brandDiscover},
+		"jcb":               {number: "3530111333300000", expBrand: "jcb"},
+		"unknown":           {number: "1234567890123456", expBrand: brandUnknown},
+		"too_short":         {number: "4", expBrand: brandVisa},
+		"empty":             {number: "", expBrand: brandUnknown},
+	}
+
+	bins, err := loadBINTable("")
+	require.NoError(t, err)
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expBrand, bins.brand(tc.number))
+		})
+	}
+}
+
+func Test_parseBINTable(t *testing.T) {
+	testCases := map[string]struct {
+		content  string
+		number   string
+		expBrand string
+		expErr   string
+	}{
+		"longest_prefix_wins": {
+			content:  "low,high,brand\n4,4,visa\n426398,426398,Privat\n",
+			number:   "4263982640269299",
+			expBrand: "privat",
+		},
+		"invalid_header": {
+			content: "from,to,brand\n4,4,visa\n",
+			expErr:  "bin table header must be low,high,brand, got from,to,brand",
+		},
+		"non_digit_prefix": {
+			content: "low,high,brand\n4x,49,visa\n",
+			expErr:  `bin table line 2: prefixes "4x" and "49" must be digits`,
+		},
+		"different_lengths": {
+			content: "low,high,brand\n4,49,visa\n",
+			expErr:  `bin table line 2: prefixes "4" and "49" must have the same length`,
+		},
+		"reversed_range": {
+			content: "low,high,brand\n55,51,mastercard\n",
+			expErr:  `bin table line 2: prefix "55" is greater than "51"`,
+		},
+		"empty_brand": {
+			content: "low,high,brand\n4,4,\n",
+			expErr:  "bin table line 2: brand must not be empty",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bins, err := parseBINTable(strings.NewReader(tc.content))
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expBrand, bins.brand(tc.number))
+		})
+	}
+}
+
+func Test_loadBINTableOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bins.csv")
+	require.NoError(t, os.WriteFile(path, []byte("low,high,brand\n9804,9804,prostir\n"), 0o600))
+
+	bins, err := loadBINTable(path)
+	require.NoError(t, err)
+	assert.Equal(t, brandProstir, bins.brand("9804000000000000"))
+	assert.Equal(t, brandUnknown, bins.brand("4263982640269299"))
+
+	_, err = loadBINTable(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.ErrorContains(t, err, "open bin table")
+}
+
+func Test_brandPolicyAccepts(t *testing.T) {
+	assert.True(t, testBrandPolicy(t).accepts(brandUnknown))
+	assert.True(t, testBrandPolicy(t, brandVisa, brandProstir).accepts(brandProstir))
+	assert.False(t, testBrandPolicy(t, brandVisa, brandProstir).accepts(brandAmex))
+}
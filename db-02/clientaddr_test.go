@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_clientAddr(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies("10.0.0.0/8,::1")
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		remoteAddr    string
+		forwardedFor  []string
+		expAddr       string
+		expErrMessage string
+	}{
+		"direct_client": {
+			remoteAddr: "198.51.100.7:4321",
+			expAddr:    "198.51.100.7",
+		},
+		"untrusted_remote_ignores_forwarded_for": {
+			remoteAddr:   "198.51.100.7:4321",
+			forwardedFor: []string{"192.0.2.1"},
+			expAddr:      "198.51.100.7",
+		},
+		"trusted_proxy_without_forwarded_for": {
+			remoteAddr: "10.0.0.1:4321",
+			expAddr:    "10.0.0.1",
+		},
+		"trusted_proxy_uses_nearest_untrusted_hop": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"203.0.113.9, 192.0.2.1"},
+			expAddr:      "192.0.2.1",
+		},
+		"skips_trusted_hops": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"192.0.2.1, 10.0.0.2"},
+			expAddr:      "192.0.2.1",
+		},
+		"multiple_header_values": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"203.0.113.9", "192.0.2.1, 10.0.0.3"},
+			expAddr:      "192.0.2.1",
+		},
+		"all_hops_trusted": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"10.0.0.5, 10.0.0.6"},
+			expAddr:      "10.0.0.5",
+		},
+		"invalid_hop_stops_walk": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"192.0.2.1, unknown"},
+			expAddr:      "10.0.0.1",
+		},
+		"trusted_ipv6_proxy": {
+			remoteAddr:   "[::1]:4321",
+			forwardedFor: []string{"2001:db8::7"},
+			expAddr:      "2001:db8::7",
+		},
+		"invalid_remote_address": {
+			remoteAddr:    "pipe",
+			expErrMessage: `parse remote address "pipe": not an ip:port`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			request.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				request.Header.Add(xForwardedForHeaderKey, value)
+			}
+
+			addr, err := clientAddr(request, trustedProxies)
+			if tc.expErrMessage != "" {
+				assert.EqualError(t, err, tc.expErrMessage)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expAddr, addr.String())
+		})
+	}
+}
+
+func Test_parseTrustedProxies(t *testing.T) {
+	testCases := map[string]struct {
+		value         string
+		expPrefixes   []netip.Prefix
+		expErrMessage string
+	}{
+		"empty": {
+			value: "",
+		},
+		"cidrs_and_addresses": {
+			value: "10.0.0.0/8, 192.0.2.1,fd00::/8, ::1",
+			expPrefixes: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("192.0.2.1/32"),
+				netip.MustParsePrefix("fd00::/8"),
+				netip.MustParsePrefix("::1/128"),
+			},
+		},
+		"unmasked_cidr": {
+			value:       "192.0.2.77/24",
+			expPrefixes: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+		},
+		"invalid_address": {
+			value:         "proxy.local",
+			expErrMessage: `ParseAddr("proxy.local"): unexpected character (at "proxy.local")`,
+		},
+		"invalid_cidr": {
+			value:         "10.0.0.0/33",
+			expErrMessage: `netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			prefixes, err := parseTrustedProxies(tc.value)
+			if tc.expErrMessage != "" {
+				assert.EqualError(t, err, tc.expErrMessage)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expPrefixes, prefixes)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRouteTimeouts(t *testing.T) {
+	testCases := map[string]struct {
+		value       string
+		expTimeouts map[string]time.Duration
+		expErr      bool
+	}{
+		"empty": {
+			value:       "",
+			expTimeouts: map[string]time.Duration{},
+		},
+		"several_routes": {
+			value: "GET /cards=2s, PUT /cards/{id}=500ms",
+			expTimeouts: map[string]time.Duration{
+				"GET /cards":      2 * time.Second,
+				"PUT /cards/{id}": 500 * time.Millisecond,
+			},
+		},
+		"missing_duration": {
+			value:  "GET /cards",
+			expErr: true,
+		},
+		"invalid_duration": {
+			value:  "GET /cards=soon",
+			expErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			timeouts, err := parseRouteTimeouts(tc.value)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expTimeouts, timeouts)
+		})
+	}
+}
+
+func Test_parseIntList(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		expValues []int
+		expErr    bool
+	}{
+		"empty": {
+			value: "",
+		},
+		"several_values": {
+			value:     "666, 999",
+			expValues: []int{666, 999},
+		},
+		"not_a_number": {
+			value:  "666,abc",
+			expErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			values, err := parseIntList(tc.value)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expValues, values)
+		})
+	}
+}
+
+func Test_parseRouteRateLimits(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		expLimits map[string]rateLimit
+		expErr    bool
+	}{
+		"empty": {
+			value:     "",
+			expLimits: map[string]rateLimit{},
+		},
+		"several_routes": {
+			value: "GET /cards=20/1s, POST /cards/{id}/reveal=5/1m, DELETE /cards/{id}=0/1s",
+			expLimits: map[string]rateLimit{
+				"GET /cards":              {Limit: 20, Window: time.Second},
+				"POST /cards/{id}/reveal": {Limit: 5, Window: time.Minute},
+				"DELETE /cards/{id}":      {Limit: 0, Window: time.Second},
+			},
+		},
+		"missing_limit": {
+			value:  "GET /cards",
+			expErr: true,
+		},
+		"missing_window": {
+			value:  "GET /cards=20",
+			expErr: true,
+		},
+		"invalid_requests": {
+			value:  "GET /cards=many/1s",
+			expErr: true,
+		},
+		"negative_requests": {
+			value:  "GET /cards=-1/1s",
+			expErr: true,
+		},
+		"invalid_window": {
+			value:  "GET /cards=20/soon",
+			expErr: true,
+		},
+		"zero_window": {
+			value:  "GET /cards=20/0s",
+			expErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			limits, err := parseRouteRateLimits(tc.value)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expLimits, limits)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+//go:embed data/country_policy.json
+var bundledCountryPolicy []byte
+
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+type countryRule struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type countryPolicyFile struct {
+	Default countryRule            `json:"default"`
+	Routes  map[string]countryRule `json:"routes"`
+}
+
+type countryPolicy struct {
+	defaultRule countryRule
+	routes      map[string]countryRule
+}
+
+type countryPolicyStore struct {
+	path    string
+	current atomic.Pointer[countryPolicy]
+}
+
+func loadCountryPolicy(path string) (*countryPolicyStore, error) {
+	store := &countryPolicyStore{path: path}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *countryPolicyStore) reload() error {
+	raw := bundledCountryPolicy
+	if s.path != "" {
+		var err error
+		raw, err = os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("read country policy: %w", err)
+		}
+	}
+
+	policy, err := parseCountryPolicy(raw)
+	if err != nil {
+		return err
+	}
+
+	s.current.Store(policy)
+	return nil
+}
+
+func (s *countryPolicyStore) allows(pattern, code string) bool {
+	return s.current.Load().rule(pattern).allows(code)
+}
+
+func watchCountryPolicy(ctx context.Context, logger *slog.Logger, store *countryPolicyStore, reloads <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloads:
+		}
+
+		if err := store.reload(); err != nil {
+			logger.Error("reload country policy, keeping the previous one", slog.String("path", store.path), slog.Any("error", err))
+			continue
+		}
+		logger.Info("country policy reloaded", slog.String("path", store.path))
+	}
+}
+
+func parseCountryPolicy(raw []byte) (*countryPolicy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var file countryPolicyFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode country policy: %w", err)
+	}
+
+	if err := file.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default country policy: %w", err)
+	}
+
+	for route, rule := range file.Routes {
+		if err := validateRouteKey(route); err != nil {
+			return nil, fmt.Errorf("country policy route %q: %w", route, err)
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("country policy route %q: %w", route, err)
+		}
+	}
+
+	return &countryPolicy{defaultRule: file.Default, routes: file.Routes}, nil
+}
+
+func validateRouteKey(route string) error {
+	path := route
+	if method, rest, ok := strings.Cut(route, " "); ok {
+		if !slices.Contains(routeMethods, method) {
+			return fmt.Errorf("unknown method %q", method)
+		}
+		path = rest
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+
+	return nil
+}
+
+func (r countryRule) validate() error {
+	for _, code := range slices.Concat(r.Allow, r.Deny) {
+		if !isISO3166Alpha2(code) {
+			return fmt.Errorf("%q is not an ISO 3166-1 alpha-2 country code", code)
+		}
+	}
+
+	return nil
+}
+
+func (r countryRule) allows(code string) bool {
+	if !isISO3166Alpha2(code) || slices.Contains(r.Deny, code) {
+		return false
+	}
+
+	return len(r.Allow) == 0 || slices.Contains(r.Allow, code)
+}
+
+func (p *countryPolicy) rule(pattern string) countryRule {
+	if rule, ok := p.routes[pattern]; ok {
+		return rule
+	}
+
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		if rule, ok := p.routes[path]; ok {
+			return rule
+		}
+	}
+
+	return p.defaultRule
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseCountryPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		expErr  string
+	}{
+		"valid": {
+			content: `{"default":{"allow":["UA","US","GB"]},"routes":{"POST /cards/{id}/reveal":{"allow":["UA"]},"/cards":{"deny":["RU"]}}}`,
+		},
+		"empty": {
+			content: `{}`,
+		},
+		"non_iso_default": {
+			content: `{"default":{"allow":["UA","UK"]}}`,
+			expErr:  `default country policy: "UK" is not an ISO 3166-1 alpha-2 country code`,
+		},
+		"lowercase_code": {
+			content: `{"default":{"deny":["ru"]}}`,
+			expErr:  `default country policy: "ru" is not an ISO 3166-1 alpha-2 country code`,
+		},
+		"non_iso_route": {
+			content: `{"routes":{"GET /cards":{"deny":["XX"]}}}`,
+			expErr:  `country policy route "GET /cards": "XX" is not an ISO 3166-1 alpha-2 country code`,
+		},
+		"unknown_method": {
+			content: `{"routes":{"FETCH /cards":{"allow":["UA"]}}}`,
+			expErr:  `country policy route "FETCH /cards": unknown method "FETCH"`,
+		},
+		"relative_path": {
+			content: `{"routes":{"cards":{"allow":["UA"]}}}`,
+			expErr:  `country policy route "cards": path must start with /`,
+		},
+		"unknown_field": {
+			content: `{"default":{"allowed":["UA"]}}`,
+			expErr:  `decode country policy: json: unknown field "allowed"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseCountryPolicy([]byte(tc.content))
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
+
+func Test_countryPolicyAllows(t *testing.T) {
+	policy, err := parseCountryPolicy([]byte(`{
+		"default": {"allow": ["UA", "US", "GB"]},
+		"routes": {
+			"POST /cards/{id}/reveal": {"allow": ["UA"]},
+			"/cards/{id}": {"deny": ["US"]},
+			"GET /admin/cards/duplicates": {}
+		}
+	}`))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		pattern  string
+		code     string
+		expAllow bool
+	}{
+		"default_allowed":           {pattern: "GET /cards", code: "GB", expAllow: true},
+		"default_not_listed":        {pattern: "GET /cards", code: "FR", expAllow: false},
+		"invalid_code":              {pattern: "GET /cards", code: "UK", expAllow: false},
+		"missing_code":              {pattern: "GET /cards", code: "", expAllow: false},
+		"method_route_allowed":      {pattern: "POST /cards/{id}/reveal", code: "UA", expAllow: true},
+		"method_route_not_listed":   {pattern: "POST /cards/{id}/reveal", code: "US", expAllow: false},
+		"path_route_denied":         {pattern: "DELETE /cards/{id}", code: "US", expAllow: false},
+		"path_route_without_allow":  {pattern: "GET /cards/{id}", code: "FR", expAllow: true},
+		"empty_route_allows_any":    {pattern: "GET /admin/cards/duplicates", code: "JP", expAllow: true},
+		"empty_route_rejects_noiso": {pattern: "GET /admin/cards/duplicates", code: "EU", expAllow: false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expAllow, policy.rule(tc.pattern).allows(tc.code))
+		})
+	}
+}
+
+func Test_countryPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"default":{"allow":["UA"]}}`), 0o600))
+
+	countries, err := loadCountryPolicy(path)
+	require.NoError(t, err)
+	assert.True(t, countries.allows("GET /cards", "UA"))
+	assert.False(t, countries.allows("GET /cards", "PL"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan os.Signal)
+	go watchCountryPolicy(ctx, discardLogger, countries, reloads)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"default":{"allow":["UA","PL"]}}`), 0o600))
+	reloads <- syscall.SIGHUP
+	assert.Eventually(t, func() bool { return countries.allows("GET /cards", "PL") }, time.Second, time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"default":{"allow":["UK"]}}`), 0o600))
+	reloads <- syscall.SIGHUP
+	reloads <- syscall.SIGHUP
+	assert.True(t, countries.allows("GET /cards", "PL"))
+
+	_, err = loadCountryPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func Test_bundledCountryPolicy(t *testing.T) {
+	countries, err := loadCountryPolicy("")
+	require.NoError(t, err)
+
+	for _, code := range []string{uaCountryCode, usCountryCode, gbCountryCode} {
+		assert.True(t, countries.allows("GET /cards", code), code)
+	}
+	assert.False(t, countries.allows("GET /cards", "UK"))
+}
+
+func Test_iso3166Alpha2Codes(t *testing.T) {
+	assert.Len(t, iso3166Alpha2Codes, 249)
+	assert.IsIncreasing(t, iso3166Alpha2Codes)
+	assert.True(t, isISO3166Alpha2("GB"))
+	assert.False(t, isISO3166Alpha2("UK"))
+}
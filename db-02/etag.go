@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func cardETag(card creditCard) string {
+	return `"` + strconv.Itoa(card.Version) + `"`
+}
+
+func parseIfMatch(header string) (versions []int, matchAny bool) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, false
+}
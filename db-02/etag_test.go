@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseIfMatch(t *testing.T) {
+	testCases := map[string]struct {
+		header      string
+		expVersions []int
+		expAny      bool
+	}{
+		"single": {
+			header:      `"3"`,
+			expVersions: []int{3},
+		},
+		"list": {
+			header:      `"3", "5"`,
+			expVersions: []int{3, 5},
+		},
+		"any": {
+			header: "*",
+			expAny: true,
+		},
+		"weak_and_malformed_ignored": {
+			header:      `W/"3", 4, "x", "6"`,
+			expVersions: []int{6},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			versions, matchAny := parseIfMatch(tc.header)
+			assert.Equal(t, tc.expVersions, versions)
+			assert.Equal(t, tc.expAny, matchAny)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidExpirationDate = errors.New("invalid expiration date")
+
+var (
+	expirationMonthYearRegexp = regexp.MustCompile(`^(0[1-9]|1[0-2])/([0-9]{2}|[0-9]{4})$`)
+	expirationISORegexp       = regexp.MustCompile(`^([0-9]{4})-(0[1-9]|1[0-2])$`)
+	expirationUkrainianRegexp = regexp.MustCompile(`^(?:([0-9]{1,2})\s+)?(\p{L}+)\s+([0-9]{4})\s*(?:р\.?|року)?$`)
+)
+
+var ukrainianMonths = map[string]time.Month{
+	"січня": time.January, "січень": time.January,
+	"лютого": time.February, "лютий": time.February,
+	"березня": time.March, "березень": time.March,
+	"квітня": time.April, "квітень": time.April,
+	"травня": time.May, "травень": time.May,
+	"червня": time.June, "червень": time.June,
+	"липня": time.July, "липень": time.July,
+	"серпня": time.August, "серпень": time.August,
+	"вересня": time.September, "вересень": time.September,
+	"жовтня": time.October, "жовтень": time.October,
+	"листопада": time.November, "листопад": time.November,
+	"грудня": time.December, "грудень": time.December,
+}
+
+type expirationDate struct {
+	Year  int
+	Month time.Month
+}
+
+func expirationDateOf(t time.Time) expirationDate {
+	return expirationDate{Year: t.Year(), Month: t.Month()}
+}
+
+func parseExpirationDate(value string) (expirationDate, error) {
+	value = strings.TrimSpace(value)
+
+	if m := expirationMonthYearRegexp.FindStringSubmatch(value); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		year, _ := strconv.Atoi(m[2])
+		if len(m[2]) == 2 {
+			year += 2000
+		}
+		return expirationDate{Year: year, Month: time.Month(month)}, nil
+	}
+
+	if m := expirationISORegexp.FindStringSubmatch(value); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		return expirationDate{Year: year, Month: time.Month(month)}, nil
+	}
+
+	if m := expirationUkrainianRegexp.FindStringSubmatch(value); m != nil {
+		month, ok := ukrainianMonths[strings.ToLower(m[2])]
+		if !ok {
+			return expirationDate{}, fmt.Errorf("%w: unknown month %q", errInvalidExpirationDate, m[2])
+		}
+		year, _ := strconv.Atoi(m[3])
+		if m[1] != "" {
+			day, _ := strconv.Atoi(m[1])
+			if t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC); t.Day() != day {
+				return expirationDate{}, fmt.Errorf("%w: day %d is out of range", errInvalidExpirationDate, day)
+			}
+		}
+		return expirationDate{Year: year, Month: month}, nil
+	}
+
+	return expirationDate{}, fmt.Errorf("%w: %q", errInvalidExpirationDate, value)
+}
+
+func (d expirationDate) IsZero() bool {
+	return d == expirationDate{}
+}
+
+func (d expirationDate) Time() time.Time {
+	return time.Date(d.Year, d.Month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (d expirationDate) Compare(other expirationDate) int {
+	return d.Time().Compare(other.Time())
+}
+
+func (d expirationDate) ExpiredAt(now time.Time) bool {
+	return d.Compare(expirationDateOf(now.UTC())) < 0
+}
+
+func (d expirationDate) String() string {
+	if d.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%02d/%02d", int(d.Month), d.Year%100)
+}
+
+func (d expirationDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
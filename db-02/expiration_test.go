@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testExpirationDate(value string) expirationDate {
+	expiration, err := parseExpirationDate(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return expiration
+}
+
+func Test_parseExpirationDate(t *testing.T) {
+	testCases := map[string]struct {
+		value         string
+		expExpiration expirationDate
+		expErr        bool
+	}{
+		"mm_yy":                 {value: "12/43", expExpiration: expirationDate{Year: 2043, Month: time.December}},
+		"mm_yyyy":               {value: "03/2031", expExpiration: expirationDate{Year: 2031, Month: time.March}},
+		"iso":                   {value: "2030-07", expExpiration: expirationDate{Year: 2030, Month: time.July}},
+		"ukrainian_long":        {value: "21 січня 2023р", expExpiration: expirationDate{Year: 2023, Month: time.January}},
+		"ukrainian_long_dot":    {value: "5 Листопада 2029 р.", expExpiration: expirationDate{Year: 2029, Month: time.November}},
+		"ukrainian_without_day": {value: "грудень 2030 року", expExpiration: expirationDate{Year: 2030, Month: time.December}},
+		"surrounding_spaces":    {value: " 12/43 ", expExpiration: expirationDate{Year: 2043, Month: time.December}},
+		"month_out_of_range":    {value: "13/43", expErr: true},
+		"three_digit_year":      {value: "12/430", expErr: true},
+		"iso_with_day":          {value: "2030-07-01", expErr: true},
+		"unknown_month":         {value: "21 january 2023", expErr: true},
+		"day_out_of_range":      {value: "30 лютого 2030", expErr: true},
+		"empty":                 {value: "", expErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expiration, err := parseExpirationDate(tc.value)
+			if tc.expErr {
+				assert.ErrorIs(t, err, errInvalidExpirationDate)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expExpiration, expiration)
+		})
+	}
+}
+
+func Test_expirationDateExpiredAt(t *testing.T) {
+	now := time.Date(2026, time.March, 31, 23, 59, 0, 0, time.UTC)
+
+	assert.True(t, expirationDate{Year: 2026, Month: time.February}.ExpiredAt(now))
+	assert.False(t, expirationDate{Year: 2026, Month: time.March}.ExpiredAt(now))
+	assert.False(t, expirationDate{Year: 2026, Month: time.April}.ExpiredAt(now))
+}
+
+func Test_expirationDateMarshalJSON(t *testing.T) {
+	raw, err := json.Marshal(expirationDate{Year: 2031, Month: time.March})
+	require.NoError(t, err)
+	assert.Equal(t, `"03/31"`, string(raw))
+}
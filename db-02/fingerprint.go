@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+const minFingerprintKeySize = 32
+
+type panFingerprinter struct {
+	key []byte
+}
+
+func newPANFingerprinter(encodedKey string) (*panFingerprinter, error) {
+	if encodedKey == "" {
+		key := make([]byte, minFingerprintKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate fingerprint key: %w", err)
+		}
+		return &panFingerprinter{key: key}, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode fingerprint key: %w", err)
+	}
+	if len(key) < minFingerprintKeySize {
+		return nil, fmt.Errorf("fingerprint key must be at least %d bytes, got %d", minFingerprintKeySize, len(key))
+	}
+
+	return &panFingerprinter{key: key}, nil
+}
+
+func (f *panFingerprinter) fingerprint(pan string) []byte {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(pan))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+type fingerprintBackfillRow struct {
+	id      int
+	owner   string
+	version int
+	number  string
+	deleted bool
+}
+
+func (s *postgresCardStore) CountUnfingerprintedCards(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM credit_cards WHERE number_fingerprint IS NULL").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count unfingerprinted credit cards: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s *postgresCardStore) BackfillFingerprints(ctx context.Context, fingerprints *panFingerprinter, batchSize int) (int, int, error) {
+	filled, duplicates := 0, 0
+	lastID := 0
+	for {
+		batch, err := s.fingerprintBackfillBatch(ctx, lastID, batchSize)
+		if err != nil {
+			return filled, duplicates, err
+		}
+		if len(batch) == 0 {
+			return filled, duplicates, nil
+		}
+
+		n, d, err := s.backfillBatch(ctx, fingerprints, batch)
+		filled += n
+		duplicates += d
+		if err != nil {
+			return filled, duplicates, err
+		}
+		s.logger.Debug("fingerprinted credit card batch", slog.Int("count", n), slog.Int("duplicates", d), slog.Int("last_id", batch[len(batch)-1].id))
+
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func (s *postgresCardStore) fingerprintBackfillBatch(ctx context.Context, afterID, batchSize int) ([]fingerprintBackfillRow, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+cardColumns+" FROM credit_cards WHERE id > $1 AND number_fingerprint IS NULL ORDER BY id LIMIT $2", afterID, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query credit cards to fingerprint: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []fingerprintBackfillRow
+	for rows.Next() {
+		card, err := s.scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan credit card to fingerprint: %w", err)
+		}
+
+		batch = append(batch, fingerprintBackfillRow{id: card.ID, owner: card.OwnerID, version: card.Version, number: card.Number, deleted: card.DeletedAt != nil})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate credit cards to fingerprint: %w", err)
+	}
+
+	return batch, nil
+}
+
+func (s *postgresCardStore) backfillBatch(ctx context.Context, fingerprints *panFingerprinter, batch []fingerprintBackfillRow) (int, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin fingerprint backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	filled, duplicates := 0, 0
+	for _, row := range batch {
+		fingerprint := fingerprints.fingerprint(row.number)
+
+		var duplicateOf sql.NullInt64
+		if !row.deleted {
+			err := tx.QueryRowContext(ctx, "SELECT id FROM credit_cards WHERE owner_id=$1 AND number_fingerprint=$2 AND id<>$3 AND deleted_at IS NULL AND duplicate_of IS NULL", row.owner, fingerprint, row.id).Scan(&duplicateOf)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return 0, 0, fmt.Errorf("query duplicate of credit card %d: %w", row.id, err)
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, "UPDATE credit_cards SET number_fingerprint=$1, duplicate_of=$2 WHERE id=$3 AND version=$4",
+			fingerprint, duplicateOf, row.id, row.version)
+		if err != nil {
+			return 0, 0, fmt.Errorf("exec fingerprint credit card %d: %w", row.id, err)
+		}
+
+		numRowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, fmt.Errorf("rows affected on fingerprint: %w", err)
+		}
+		filled += int(numRowsAffected)
+		if numRowsAffected == 1 && duplicateOf.Valid {
+			duplicates++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit fingerprint backfill: %w", err)
+	}
+
+	return filled, duplicates, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresCardStore_BackfillFingerprints(t *testing.T) {
+	testCases := map[string]struct {
+		batchSize int
+	}{
+		"single_batch":    {batchSize: 10},
+		"several_batches": {batchSize: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := openTestDB(t)
+			store := &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+			seedCards(t, store, []creditCard{
+				{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко", Brand: brandVisa},
+				{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро", Brand: brandMastercard},
+				{Number: "4263982640269299", ExpirationDate: testExpirationDate("02/31"), Holder: "Іван", Brand: brandVisa},
+				{Number: "4263982640269299", ExpirationDate: testExpirationDate("03/32"), Holder: "Ваня", Brand: brandVisa},
+			})
+			require.NoError(t, store.DeleteCard(context.Background(), "", 4, auditEntry{}))
+
+			unfingerprinted, err := store.CountUnfingerprintedCards(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 4, unfingerprinted)
+			assert.Error(t, checkUnfingerprintedCards(store))
+
+			fingerprints := testFingerprinter(t)
+			filled, duplicates, err := store.BackfillFingerprints(context.Background(), fingerprints, tc.batchSize)
+			require.NoError(t, err)
+			assert.Equal(t, 4, filled)
+			assert.Equal(t, 1, duplicates)
+			assert.NoError(t, checkUnfingerprintedCards(store))
+
+			rows, err := db.Query("SELECT number_fingerprint, duplicate_of FROM credit_cards ORDER BY id")
+			require.NoError(t, err)
+			defer rows.Close()
+
+			var duplicateOf []sql.NullInt64
+			for rows.Next() {
+				var fingerprint []byte
+				var row sql.NullInt64
+				require.NoError(t, rows.Scan(&fingerprint, &row))
+				assert.NotEmpty(t, fingerprint)
+				duplicateOf = append(duplicateOf, row)
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, []sql.NullInt64{{}, {}, {Int64: 1, Valid: true}, {}}, duplicateOf)
+
+			groups, err := store.ListDuplicateCards(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []duplicateCardGroup{{Last4: "9299", Brand: brandVisa, CardIDs: []int{1, 3, 4}}}, groups)
+
+			_, err = store.SaveCard(context.Background(), creditCard{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро", Brand: brandMastercard, Fingerprint: fingerprints.fingerprint("5375414100000000")}, auditEntry{})
+			var duplicate *duplicateCardError
+			require.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, 2, duplicate.ExistingID)
+
+			filled, _, err = store.BackfillFingerprints(context.Background(), fingerprints, tc.batchSize)
+			require.NoError(t, err)
+			assert.Zero(t, filled)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFingerprinter(t *testing.T) *panFingerprinter {
+	t.Helper()
+
+	fingerprints, err := newPANFingerprinter(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, minFingerprintKeySize)))
+	require.NoError(t, err)
+
+	return fingerprints
+}
+
+func Test_newPANFingerprinter(t *testing.T) {
+	testCases := map[string]struct {
+		key    string
+		expErr string
+	}{
+		"generated":     {key: ""},
+		"valid":         {key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 48))},
+		"not_base64":    {key: "not base64!", expErr: "decode fingerprint key"},
+		"key_too_short": {key: base64.StdEncoding.EncodeToString([]byte("short")), expErr: "fingerprint key must be at least 32 bytes, got 5"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fingerprints, err := newPANFingerprinter(tc.key)
+			if tc.expErr != "" {
+				assert.ErrorContains(t, err, tc.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, fingerprints.fingerprint("4263982640269299"), 32)
+		})
+	}
+}
+
+func Test_panFingerprinterFingerprint(t *testing.T) {
+	fingerprints := testFingerprinter(t)
+
+	assert.Equal(t, fingerprints.fingerprint("4263982640269299"), fingerprints.fingerprint("4263982640269299"))
+	assert.NotEqual(t, fingerprints.fingerprint("4263982640269299"), fingerprints.fingerprint("5375414100000000"))
+
+	other, err := newPANFingerprinter("")
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprints.fingerprint("4263982640269299"), other.fingerprint("4263982640269299"))
+}
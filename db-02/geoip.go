@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type geoIPDatabase struct {
+	reader         *maxminddb.Reader
+	trustedProxies []netip.Prefix
+}
+
+func openGeoIPDatabase(path string, trustedProxies []netip.Prefix) (*geoIPDatabase, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open geoip database %s: %w", path, err)
+	}
+
+	return &geoIPDatabase{reader: reader, trustedProxies: trustedProxies}, nil
+}
+
+func (g *geoIPDatabase) close() error {
+	return g.reader.Close()
+}
+
+func (g *geoIPDatabase) lookup(addr netip.Addr) (string, error) {
+	var record geoIPRecord
+	if err := g.reader.Lookup(net.IP(addr.Unmap().AsSlice()), &record); err != nil {
+		return "", fmt.Errorf("lookup %s: %w", addr, err)
+	}
+
+	return strings.ToUpper(record.Country.ISOCode), nil
+}
+
+func (g *geoIPDatabase) resolveCountry(r *http.Request) (string, netip.Addr, error) {
+	addr, err := clientAddr(r, g.trustedProxies)
+	if err != nil {
+		return "", netip.Addr{}, err
+	}
+
+	country, err := g.lookup(addr)
+	if err != nil {
+		return "", addr, err
+	}
+
+	return country, addr, nil
+}
+
+type countryContextKey struct{}
+
+func countryMiddleware(logger *slog.Logger, geoip *geoIPDatabase, next http.HandlerFunc) http.HandlerFunc {
+	if geoip == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		country, addr, err := geoip.resolveCountry(r)
+		if err != nil {
+			logger.Warn("resolve country", slog.String("request_id", requestID(r)), slog.Any("error", err))
+		} else if header := r.Header.Get(xCountryCodeHeaderKey); header != "" && !strings.EqualFold(header, country) {
+			logger.Warn("country mismatch",
+				slog.String("request_id", requestID(r)),
+				slog.String("client_ip", addr.String()),
+				slog.String("header_country", header),
+				slog.String("geoip_country", country),
+			)
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), countryContextKey{}, country)))
+	}
+}
+
+func requestCountry(r *http.Request) string {
+	if country, ok := r.Context().Value(countryContextKey{}).(string); ok {
+		return country
+	}
+
+	return r.Header.Get(xCountryCodeHeaderKey)
+}
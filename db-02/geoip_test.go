@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testGeoIPNetworks = map[string]map[string]any{
+	"192.0.2.0/24":    {"country": map[string]any{"iso_code": uaCountryCode}},
+	"198.51.100.0/24": {"country": map[string]any{"iso_code": usCountryCode}},
+	"203.0.113.0/24":  {"country": map[string]any{"iso_code": "FR"}},
+	"2001:db8::/32":   {"country": map[string]any{"iso_code": gbCountryCode}},
+	"100.64.0.0/10":   {},
+}
+
+func openTestGeoIPDatabase(t *testing.T, trustedProxies ...string) *geoIPDatabase {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	require.NoError(t, os.WriteFile(path, buildTestMMDB(t, testGeoIPNetworks), 0o600))
+
+	prefixes, err := parseTrustedProxies(strings.Join(trustedProxies, ","))
+	require.NoError(t, err)
+
+	geoip, err := openGeoIPDatabase(path, prefixes)
+	require.NoError(t, err)
+	require.NoError(t, geoip.reader.Verify())
+	t.Cleanup(func() { geoip.close() })
+
+	return geoip
+}
+
+func buildTestMMDB(t *testing.T, networks map[string]map[string]any) []byte {
+	const emptyRecord = -1
+
+	nodes := [][2]int{{emptyRecord, emptyRecord}}
+	var data bytes.Buffer
+	var offsets []int
+
+	for network, record := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		require.NoError(t, err)
+
+		addr, bits := prefix.Addr().As16(), prefix.Bits()
+		if prefix.Addr().Is4() {
+			addr = [16]byte{}
+			v4 := prefix.Addr().As4()
+			copy(addr[12:], v4[:])
+			bits += 96
+		}
+
+		offsets = append(offsets, data.Len())
+		encodeTestMMDBValue(&data, record)
+
+		node := 0
+		for i := 0; i < bits; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if i == bits-1 {
+				nodes[node][bit] = -2 - (len(offsets) - 1)
+				break
+			}
+
+			next := nodes[node][bit]
+			if next == emptyRecord {
+				nodes = append(nodes, [2]int{emptyRecord, emptyRecord})
+				next = len(nodes) - 1
+				nodes[node][bit] = next
+			}
+			node = next
+		}
+	}
+
+	var db bytes.Buffer
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			switch {
+			case record == emptyRecord:
+				value = len(nodes)
+			case record < emptyRecord:
+				value = len(nodes) + 16 + offsets[-2-record]
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	encodeTestMMDBValue(&db, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "Test-Country",
+		"description":                 map[string]any{"en": "test fixture"},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	})
+
+	return db.Bytes()
+}
+
+func encodeTestMMDBValue(buf *bytes.Buffer, value any) {
+	control := func(kind, size int) {
+		if kind > 7 {
+			buf.Write([]byte{byte(size), byte(kind - 7)})
+			return
+		}
+		buf.WriteByte(byte(kind<<5 | size))
+	}
+	unsigned := func(kind int, n uint64) {
+		raw := binary.BigEndian.AppendUint64(nil, n)
+		raw = bytes.TrimLeft(raw, "\x00")
+		control(kind, len(raw))
+		buf.Write(raw)
+	}
+
+	switch v := value.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		unsigned(5, uint64(v))
+	case uint32:
+		unsigned(6, uint64(v))
+	case uint64:
+		unsigned(9, v)
+	case []any:
+		control(11, len(v))
+		for _, item := range v {
+			encodeTestMMDBValue(buf, item)
+		}
+	case map[string]any:
+		control(7, len(v))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			encodeTestMMDBValue(buf, key)
+			encodeTestMMDBValue(buf, v[key])
+		}
+	}
+}
+
+func Test_geoIPDatabaseLookup(t *testing.T) {
+	geoip := openTestGeoIPDatabase(t)
+
+	testCases := map[string]struct {
+		addr       string
+		expCountry string
+	}{
+		"ipv4": {
+			addr:       "192.0.2.17",
+			expCountry: uaCountryCode,
+		},
+		"other_ipv4_network": {
+			addr:       "198.51.100.200",
+			expCountry: usCountryCode,
+		},
+		"ipv4_mapped_ipv6": {
+			addr:       "::ffff:203.0.113.5",
+			expCountry: "FR",
+		},
+		"ipv6": {
+			addr:       "2001:db8:1::1",
+			expCountry: gbCountryCode,
+		},
+		"network_without_country": {
+			addr:       "100.64.1.1",
+			expCountry: "",
+		},
+		"unknown_address": {
+			addr:       "10.1.2.3",
+			expCountry: "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			country, err := geoip.lookup(netip.MustParseAddr(tc.addr))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expCountry, country)
+		})
+	}
+}
+
+func Test_countryMiddleware(t *testing.T) {
+	countries, err := loadCountryPolicy("")
+	require.NoError(t, err)
+
+	geoip := openTestGeoIPDatabase(t, "10.0.0.0/8")
+
+	testCases := map[string]struct {
+		geoip         *geoIPDatabase
+		remoteAddr    string
+		forwardedFor  string
+		countryCode   string
+		expCountry    string
+		expStatusCode int
+		expBody       string
+		expLog        string
+	}{
+		"header_without_geoip": {
+			remoteAddr:    "203.0.113.5:4321",
+			countryCode:   uaCountryCode,
+			expCountry:    uaCountryCode,
+			expStatusCode: http.StatusAccepted,
+		},
+		"geoip_without_header": {
+			geoip:         geoip,
+			remoteAddr:    "198.51.100.7:4321",
+			expCountry:    usCountryCode,
+			expStatusCode: http.StatusAccepted,
+		},
+		"geoip_matching_header": {
+			geoip:         geoip,
+			remoteAddr:    "192.0.2.7:4321",
+			countryCode:   uaCountryCode,
+			expCountry:    uaCountryCode,
+			expStatusCode: http.StatusAccepted,
+		},
+		"geoip_overrides_spoofed_header": {
+			geoip:         geoip,
+			remoteAddr:    "203.0.113.5:4321",
+			countryCode:   uaCountryCode,
+			expCountry:    "FR",
+			expStatusCode: http.StatusForbidden,
+			expBody:       testProblem(problemCountryNotAllowed, `country "FR" is not allowed`, nil),
+			expLog:        "level=WARN msg=\"country mismatch\" request_id=\"\" client_ip=203.0.113.5 header_country=UA geoip_country=FR\n",
+		},
+		"geoip_behind_trusted_proxy": {
+			geoip:         geoip,
+			remoteAddr:    "10.0.0.1:4321",
+			forwardedFor:  "203.0.113.5, 192.0.2.7",
+			countryCode:   uaCountryCode,
+			expCountry:    uaCountryCode,
+			expStatusCode: http.StatusAccepted,
+		},
+		"geoip_unknown_address": {
+			geoip:         geoip,
+			remoteAddr:    "10.0.0.1:4321",
+			countryCode:   gbCountryCode,
+			expCountry:    "",
+			expStatusCode: http.StatusForbidden,
+			expBody:       testProblem(problemCountryNotAllowed, `country "" is not allowed`, nil),
+			expLog:        "level=WARN msg=\"country mismatch\" request_id=\"\" client_ip=10.0.0.1 header_country=GB geoip_country=\"\"\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			request.RemoteAddr = tc.remoteAddr
+			request.Header.Set(xCountryCodeHeaderKey, tc.countryCode)
+			if tc.forwardedFor != "" {
+				request.Header.Set(xForwardedForHeaderKey, tc.forwardedFor)
+			}
+
+			var country string
+			var logs bytes.Buffer
+			rw := httptest.NewRecorder()
+			countryMiddleware(newTestLogger(&logs), tc.geoip, func(writer http.ResponseWriter, request *http.Request) {
+				country = requestCountry(request)
+				isCountryAllowedMiddleware(countries, "GET /cards", func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusAccepted)
+				})(writer, request)
+			})(rw, request)
+
+			assert.Equal(t, tc.expCountry, country)
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expLog, logs.String())
+		})
+	}
+}
+
+func Test_openGeoIPDatabase_invalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o600))
+
+	_, err := openGeoIPDatabase(path, nil)
+	assert.ErrorAs(t, err, new(maxminddb.InvalidDatabaseError))
+}
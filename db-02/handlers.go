@@ -1,145 +1,355 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
-	"regexp"
+	"slices"
 	"strconv"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 )
 
-type storageSaveCardFunc = func(card creditCard) error
-
-func createCard(storageSaveCard storageSaveCardFunc) http.HandlerFunc {
+func createCard(store CardStore, verifier cardVerifier, brands *brandPolicy, fingerprints *panFingerprinter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyUnreadable))
 			return
 		}
 		r.Body.Close()
 
-		var reqCard creditCard
-		err = json.Unmarshal(body, &reqCard)
+		var req cardRequest
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyNotCreditCard))
+			return
+		}
+
+		err = validate(requestLanguage(r), req, true)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
+			writeValidationProblem(w, r, err)
+			return
+		}
+
+		if !detectBrand(w, r, brands, &req) {
+			return
+		}
+		req.Fingerprint = fingerprints.fingerprint(req.Number)
+
+		if !verifyCard(w, r, verifier, req) {
 			return
 		}
 
-		err = validate(reqCard)
+		newCard := req.card()
+		newCard.OwnerID = requestSubject(r)
+
+		createdCard, err := store.SaveCard(r.Context(), newCard, requestAuditEntry(r))
+		if writeDuplicateError(w, r, err) {
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(err.Error()))
+			writeStorageError(w, r, err)
 			return
 		}
 
-		err = storageSaveCard(reqCard)
+		resp, err := json.Marshal(newCardResponse(createdCard))
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 			return
 		}
 
+		w.Header().Set("Location", "/cards/"+strconv.Itoa(createdCard.ID))
+		w.Header().Set("ETag", cardETag(createdCard))
 		w.WriteHeader(http.StatusCreated)
+		w.Write(resp)
 	}
 }
 
-type storageListCardsFunc = func(holder string) ([]creditCard, error)
-
-func listCards(storageListCards storageListCardsFunc) http.HandlerFunc {
+func getCard(store CardStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		holder := r.URL.Query().Get("holder")
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
 
-		creditCards, err := storageListCards(holder)
+		card, err := store.GetCard(r.Context(), requestSubject(r), id)
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeStorageError(w, r, err)
 			return
 		}
 
-		resp, err := json.Marshal(creditCards)
+		resp, err := json.Marshal(newCardResponse(card))
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 			return
 		}
 
+		w.Header().Set("ETag", cardETag(card))
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	}
 }
 
-type storageUpdateCardFunc = func(card creditCard) error
+func listCards(store CardStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseListCardsQuery(r.URL.Query())
+		if err != nil {
+			writeProblem(w, r, problemInvalidQuery, localizeError(r, err))
+			return
+		}
 
-func updateCard(storageUpdateCard storageUpdateCardFunc) http.HandlerFunc {
+		if query.IncludeDeleted && !requestPrincipal(r).hasScope(scopeCardsAdmin) {
+			writeProblem(w, r, problemForbidden, localize(r, msgIncludeDeletedAdminOnly))
+			return
+		}
+		query.OwnerID = requestSubject(r)
+
+		page, err := store.ListCards(r.Context(), query)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(newCardResponses(page.Cards))
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+		if page.HasMore {
+			w.Header().Set("Link", nextPageLink(r.URL, query, page))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func updateCard(store CardStore, verifier cardVerifier, brands *brandPolicy, fingerprints *panFingerprinter, requireIfMatch bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.Atoi(r.PathValue("id"))
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgCardIDNotInteger))
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" && requireIfMatch {
+			writeProblem(w, r, problemPreconditionRequired, "")
 			return
 		}
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyUnreadable))
 			return
 		}
 		r.Body.Close()
 
-		var reqCard creditCard
-		err = json.Unmarshal(body, &reqCard)
+		var req cardRequest
+		err = json.Unmarshal(body, &req)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusBadRequest)
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyNotCreditCard))
 			return
 		}
 
-		err = validate(reqCard)
+		err = validate(requestLanguage(r), req, true)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(err.Error()))
+			writeValidationProblem(w, r, err)
+			return
+		}
+
+		if !detectBrand(w, r, brands, &req) {
+			return
+		}
+		req.Fingerprint = fingerprints.fingerprint(req.Number)
+
+		if !verifyCard(w, r, verifier, req) {
 			return
 		}
 
+		reqCard := req.card()
 		reqCard.ID = id
+		reqCard.OwnerID = requestSubject(r)
 
-		err = storageUpdateCard(reqCard)
-		if err == errCreditCardNotFound {
-			w.WriteHeader(http.StatusNotFound)
+		if ifMatch != "" {
+			var current creditCard
+			current, err = store.GetCard(r.Context(), reqCard.OwnerID, id)
+			if err == nil {
+				reqCard.Version, err = matchedVersion(current, ifMatch)
+			}
+		}
+		if err == nil {
+			reqCard, err = store.UpdateCard(r.Context(), reqCard, requestAuditEntry(r))
+		}
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+		if errors.Is(err, errVersionConflict) {
+			writeProblem(w, r, problemVersionConflict, localize(r, msgRetryWithFreshCard))
+			return
+		}
+		if writeDuplicateError(w, r, err) {
 			return
 		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeStorageError(w, r, err)
 			return
 		}
 
+		w.Header().Set("ETag", cardETag(reqCard))
 		w.WriteHeader(http.StatusOK)
-		return
 	}
 }
 
-type storageDeleteCardFunc func(id int) error
+func patchCard(store CardStore, verifier cardVerifier, brands *brandPolicy, fingerprints *panFingerprinter, requireIfMatch bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgCardIDNotInteger))
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" && requireIfMatch {
+			writeProblem(w, r, problemPreconditionRequired, "")
+			return
+		}
 
-func deleteCard(storageDeleteCard storageDeleteCardFunc) http.HandlerFunc {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyUnreadable))
+			return
+		}
+		r.Body.Close()
+
+		owner := requestSubject(r)
+		before, err := store.GetCard(r.Context(), owner, id)
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		version := 0
+		if ifMatch != "" {
+			version, err = matchedVersion(before, ifMatch)
+			if err != nil {
+				writeProblem(w, r, problemVersionConflict, localize(r, msgRetryWithFreshCard))
+				return
+			}
+		}
+
+		req, err := applyCardPatch(before, r.Header.Get("Content-Type"), body)
+		switch {
+		case errors.Is(err, errUnsupportedPatchType):
+			w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+			writeProblem(w, r, problemUnsupportedMediaType, localizeError(r, err))
+			return
+		case errors.Is(err, errMalformedPatch):
+			writeProblem(w, r, problemMalformedRequest, localizeError(r, err))
+			return
+		case errors.Is(err, errPatchNotApplicable):
+			writeProblem(w, r, problemPatchNotApplicable, localizeError(r, err))
+			return
+		case err != nil:
+			writeInternalError(w, r, err)
+			return
+		}
+
+		numberChanged := req.Number != before.Number
+		err = validate(requestLanguage(r), req, numberChanged)
+		if err != nil {
+			writeValidationProblem(w, r, err)
+			return
+		}
+
+		if numberChanged {
+			if !detectBrand(w, r, brands, &req) {
+				return
+			}
+			req.Fingerprint = fingerprints.fingerprint(req.Number)
+
+			if !verifyCard(w, r, verifier, req) {
+				return
+			}
+		}
+
+		after := before
+		if patch := diffCardPatch(before, req); !patch.isEmpty() {
+			after, err = store.PatchCard(r.Context(), owner, id, version, patch, requestAuditEntry(r))
+			if errors.Is(err, errCreditCardNotFound) {
+				writeProblem(w, r, problemCardNotFound, "")
+				return
+			}
+			if errors.Is(err, errVersionConflict) {
+				writeProblem(w, r, problemVersionConflict, localize(r, msgRetryWithFreshCard))
+				return
+			}
+			if writeDuplicateError(w, r, err) {
+				return
+			}
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+		}
+
+		resp, err := json.Marshal(newCardResponse(after))
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", cardETag(after))
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func matchedVersion(current creditCard, ifMatch string) (int, error) {
+	versions, matchAny := parseIfMatch(ifMatch)
+	if matchAny {
+		return 0, nil
+	}
+
+	if !slices.Contains(versions, current.Version) {
+		return 0, errVersionConflict
+	}
+
+	return current.Version, nil
+}
+
+func deleteCard(store CardStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.Atoi(r.PathValue("id"))
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
+			writeProblem(w, r, problemCardNotFound, "")
 			return
 		}
 
-		err = storageDeleteCard(id)
+		err = store.DeleteCard(r.Context(), requestSubject(r), id, requestAuditEntry(r))
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			writeStorageError(w, r, err)
 			return
 		}
 
@@ -147,13 +357,281 @@ func deleteCard(storageDeleteCard storageDeleteCardFunc) http.HandlerFunc {
 	}
 }
 
-func validate(card creditCard) error {
-	return validation.ValidateStruct(&card,
-		validation.Field(&card.Holder, validation.Required, validation.Length(5, 50)),
-		validation.Field(&card.CvvCode, validation.Required, validation.Min(100), validation.Max(999)),
-		validation.Field(&card.Number, validation.Required, is.CreditCard),
-		validation.Field(&card.ExpirationDate, validation.Required, validation.
-			Match(regexp.MustCompile("^(0[1-9]|1[0-2])\\/[0-9]{2}$")).
-			Error("дата не коректна")),
+func restoreCard(store CardStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+
+		card, err := store.RestoreCard(r.Context(), requestSubject(r), id, requestAuditEntry(r))
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+		if errors.Is(err, errCreditCardActive) {
+			writeProblem(w, r, problemCardNotDeleted, "")
+			return
+		}
+		if writeDuplicateError(w, r, err) {
+			return
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(newCardResponse(card))
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", cardETag(card))
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func revealCard(store CardStore, audit AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+
+		card, err := store.GetCard(r.Context(), requestSubject(r), id)
+		if errors.Is(err, errCreditCardNotFound) {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if err := recordAudit(r, audit, auditActionReveal, id); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(revealedCard{ID: card.ID, Number: card.Number})
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func cardHistory(audit AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+
+		entries, err := audit.ListAudit(r.Context(), requestSubject(r), id)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		if len(entries) == 0 {
+			writeProblem(w, r, problemCardNotFound, "")
+			return
+		}
+
+		resp, err := json.Marshal(entries)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func listDuplicateCards(store CardStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := store.ListDuplicateCards(r.Context())
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(groups)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+func createAPIKey(store APIKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyUnreadable))
+			return
+		}
+		r.Body.Close()
+
+		var req apiKeyRequest
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyNotAPIKey))
+			return
+		}
+
+		err = validateAPIKeyRequest(requestLanguage(r), req, time.Now())
+		if err != nil {
+			writeValidationProblem(w, r, err)
+			return
+		}
+
+		secret, err := newAPIKeySecret()
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		key, err := store.CreateAPIKey(r.Context(), apiKey{
+			Name:      req.Name,
+			OwnerID:   req.OwnerID,
+			Scopes:    req.Scopes,
+			Hash:      hashAPIKey(secret),
+			ExpiresAt: req.ExpiresAt,
+		})
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(createdAPIKey{apiKey: key, Key: secret})
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(resp)
+	}
+}
+
+func revokeAPIKey(store APIKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeProblem(w, r, problemAPIKeyNotFound, "")
+			return
+		}
+
+		err = store.RevokeAPIKey(r.Context(), id)
+		if errors.Is(err, errAPIKeyNotFound) {
+			writeProblem(w, r, problemAPIKeyNotFound, "")
+			return
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func detectBrand(w http.ResponseWriter, r *http.Request, brands *brandPolicy, req *cardRequest) bool {
+	req.Brand = brands.detect(req.Number)
+	if !brands.accepts(req.Brand) {
+		writeFieldProblem(w, r, problemValidationFailed, "", map[string]string{
+			"number": localize(r, msgValidationBrandNotAccepted, req.Brand),
+		})
+		return false
+	}
+
+	return true
+}
+
+func verifyCard(w http.ResponseWriter, r *http.Request, verifier cardVerifier, req cardRequest) bool {
+	err := verifier.VerifyCard(r.Context(), req.card(), req.CvvCode)
+	if errors.Is(err, errCardVerificationFailed) {
+		writeProblem(w, r, problemCardVerificationFailed, "")
+		return false
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return false
+	}
+
+	return true
+}
+
+func validate(lang string, req cardRequest, requireCVV bool) error {
+	required := validation.Required.Error(translate(lang, msgValidationRequired))
+
+	cvvRules := []validation.Rule{
+		validation.Min(100).Error(translate(lang, msgValidationMin, 100)),
+		validation.Max(999).Error(translate(lang, msgValidationMax, 999)),
+	}
+	if requireCVV {
+		cvvRules = append([]validation.Rule{required}, cvvRules...)
+	}
+
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Holder, required, validation.Length(5, 50).Error(translate(lang, msgValidationLength, 5, 50))),
+		validation.Field(&req.CvvCode, cvvRules...),
+		validation.Field(&req.Number, required, is.CreditCard.Error(translate(lang, msgValidationCreditCard))),
+		validation.Field(&req.ExpirationDate, required, validation.By(expirationDateRule(lang))),
 	)
 }
+
+func expirationDateRule(lang string) validation.RuleFunc {
+	return func(value interface{}) error {
+		expiration, err := parseExpirationDate(value.(string))
+		if err != nil {
+			return errors.New(translate(lang, msgValidationExpirationDateInvalid))
+		}
+		if expiration.ExpiredAt(time.Now()) {
+			return errors.New(translate(lang, msgValidationCardExpired))
+		}
+
+		return nil
+	}
+}
+
+func writeDuplicateError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var duplicate *duplicateCardError
+	if !errors.As(err, &duplicate) {
+		return false
+	}
+
+	w.Header().Set("Location", "/cards/"+strconv.Itoa(duplicate.ExistingID))
+	writeProblem(w, r, problemDuplicateCard, localize(r, msgDuplicateCardNumber, duplicate.ExistingID))
+	return true
+}
+
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if ctxErr := r.Context().Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeProblem(w, r, problemDeadlineExceeded, "")
+	case errors.Is(err, context.Canceled):
+		writeProblem(w, r, problemRequestCancelled, "")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
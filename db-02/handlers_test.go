@@ -1,231 +1,938 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_CardsGet(t *testing.T) {
 	testCases := map[string]struct {
-		setupStorageMock storageListCardsFunc
+		setupStorageMock CardStore
 
 		countryCode   string
+		cancelRequest bool
+		scopes        []string
 		expResp       string
+		expTotalCount string
+		expLink       string
 		expStatusCode int
 		queryParams   string
 	}{
 		"success": {
 			countryCode: usCountryCode,
-			setupStorageMock: func(holder string) []creditCard {
-				return []creditCard{
-					{
-						ID:             2983,
-						Number:         "4263982640269299",
-						ExpirationDate: "21 січня 2023р",
-						CvvCode:        123,
-						Holder:         "Іванко",
-					},
-				}
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					return cardsPage{
+						Cards: []creditCard{
+							{
+								ID:             2983,
+								Number:         "4263982640269299",
+								Brand:          brandVisa,
+								ExpirationDate: testExpirationDate("21 січня 2023р"),
+								Holder:         "Іванко",
+								CreatedAt:      testCardCreatedAt,
+							},
+						},
+						Total: 1,
+					}, nil
+				},
+			},
+			expResp:       `[{"id":2983,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"01/23","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}]`,
+			expTotalCount: "1",
+			expStatusCode: http.StatusOK,
+		},
+		"next_page": {
+			countryCode: usCountryCode,
+			queryParams: "holder=%D1%96%D0%B2&limit=1",
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					if query.Holder != "ів" || query.Limit != 1 || query.After != nil {
+						return cardsPage{}, assert.AnError
+					}
+
+					return cardsPage{
+						Cards: []creditCard{
+							{
+								ID:             7,
+								Number:         "4263982640269299",
+								Brand:          brandVisa,
+								ExpirationDate: testExpirationDate("12/43"),
+								Holder:         "Іванко",
+								CreatedAt:      testCardCreatedAt,
+							},
+						},
+						Total:   3,
+						HasMore: true,
+					}, nil
+				},
 			},
-			expResp:       `[{"id":2983,"number":"4263982640269299","expiration_date":"21 січня 2023р","cvv":123,"holder":"Іванко"}]`,
+			expResp:       `[{"id":7,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}]`,
+			expTotalCount: "3",
+			expLink:       `</cards?after=eyJpZCI6N30&holder=%D1%96%D0%B2&limit=1>; rel="next"`,
 			expStatusCode: http.StatusOK,
 		},
+		"with_cursor": {
+			countryCode: usCountryCode,
+			queryParams: "after=eyJpZCI6N30",
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					if query.After == nil || query.After.ID != 7 || query.Limit != defaultPageLimit {
+						return cardsPage{}, assert.AnError
+					}
+
+					return cardsPage{Cards: []creditCard{}, Total: 3}, nil
+				},
+			},
+			expResp:       `[]`,
+			expTotalCount: "3",
+			expStatusCode: http.StatusOK,
+		},
+		"invalid_limit": {
+			countryCode:   usCountryCode,
+			queryParams:   "limit=1000",
+			expResp:       testProblem(problemInvalidQuery, "limit must be between 1 and 100", nil),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"invalid_cursor": {
+			countryCode:   usCountryCode,
+			queryParams:   "after=42",
+			expResp:       testProblem(problemInvalidQuery, "invalid cursor", nil),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"include_deleted_for_admin": {
+			countryCode: usCountryCode,
+			queryParams: "include_deleted=true",
+			scopes:      []string{scopeCardsRead, scopeCardsAdmin},
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					if !query.IncludeDeleted {
+						return cardsPage{}, assert.AnError
+					}
+
+					return cardsPage{Cards: []creditCard{}}, nil
+				},
+			},
+			expResp:       `[]`,
+			expTotalCount: "0",
+			expStatusCode: http.StatusOK,
+		},
+		"include_deleted_forbidden_without_admin_scope": {
+			countryCode:   usCountryCode,
+			queryParams:   "include_deleted=true",
+			scopes:        defaultUserScopes,
+			expResp:       testProblem(problemForbidden, "include_deleted is only available to administrators", nil),
+			expStatusCode: http.StatusForbidden,
+		},
+		"request_cancelled": {
+			countryCode: usCountryCode,
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					return cardsPage{}, ctx.Err()
+				},
+			},
+			cancelRequest: true,
+			expResp:       testProblem(problemRequestCancelled, "", nil),
+			expStatusCode: http.StatusServiceUnavailable,
+		},
+		"deadline_exceeded": {
+			countryCode: usCountryCode,
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					return cardsPage{}, fmt.Errorf("query credit cards: %w", context.DeadlineExceeded)
+				},
+			},
+			expResp:       testProblem(problemDeadlineExceeded, "", nil),
+			expStatusCode: http.StatusGatewayTimeout,
+		},
+		"internal_server_error": {
+			countryCode: usCountryCode,
+			setupStorageMock: &cardStoreMock{
+				listCardsFunc: func(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+					return cardsPage{}, assert.AnError
+				},
+			},
+			expResp:       testProblem(problemInternalError, "", nil),
+			expStatusCode: http.StatusInternalServerError,
+		},
 	}
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			request := http.Request{
+			request := &http.Request{
 				Method: http.MethodGet,
-				URL:    &url.URL{RawQuery: tc.queryParams},
+				URL:    &url.URL{Path: "/cards", RawQuery: tc.queryParams},
 				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
 			}
+			request = withPrincipal(request, principal{Subject: "alice", Scopes: tc.scopes})
+
+			if tc.cancelRequest {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				request = request.WithContext(ctx)
+			}
 
 			rw := httptest.NewRecorder()
-			listCards(tc.setupStorageMock)(rw, &request)
+			listCards(tc.setupStorageMock)(rw, request)
 
 			resp := rw.Body.String()
 			expResp := tc.expResp
 			assert.Equal(t, expResp, resp)
+			assert.Equal(t, tc.expTotalCount, rw.Header().Get("X-Total-Count"))
+			assert.Equal(t, tc.expLink, rw.Header().Get("Link"))
+			assert.Equal(t, tc.expStatusCode, rw.Code)
 		})
 	}
 }
 
 func Test_CardsPost(t *testing.T) {
 	testCases := map[string]struct {
-		setupStorageMock storageSaveCardFunc
+		setupStorageMock CardStore
 		requestBody      io.ReadCloser
 		countryCode      string
 		expBody          string
+		expLocation      string
+		expETag          string
 		expStatusCode    int
+		expAudit         []auditEntry
 	}{
 		"empty_body": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(errMock{}),
-			expBody:       "",
+			expBody:       testProblem(problemMalformedRequest, "request body could not be read", nil),
 		},
 		"invalid_json": {
 			countryCode:   usCountryCode,
+			expBody:       testProblem(problemMalformedRequest, "request body must be a JSON credit card", nil),
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(strings.NewReader("")),
 		},
 		"invalid_card_number": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(strings.NewReader(`{"number":"42","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
-			expBody:       "number: must be a valid credit card number.",
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"number": "must be a valid credit card number"}),
 		},
 		"invalid_card_expiration_date": {
 			countryCode:   uaCountryCode,
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"122/43","cvv":123,"holder":"Іванко"}`)),
-			expBody:       "expiration_date: дата не коректна.",
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"expiration_date": "must be a valid expiration date"}),
+		},
+		"expired_card": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusBadRequest,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"21 січня 2023р","cvv":123,"holder":"Іванко"}`)),
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"expiration_date": "card has expired"}),
 		},
 		"invalid_card_svv": {
 			countryCode:   uaCountryCode,
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":12223,"holder":"Іванко"}`)),
-			expBody:       "cvv: must be no greater than 999.",
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"cvv": "must be no greater than 999"}),
 		},
 		"invalid_card_holder": {
 			countryCode:   uaCountryCode,
 			expStatusCode: http.StatusBadRequest,
 			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"І"}`)),
-			expBody:       "holder: the length must be between 5 and 50.",
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"holder": "the length must be between 5 and 50"}),
 		},
 		"success": {
-			countryCode:      uaCountryCode,
-			expStatusCode:    http.StatusCreated,
-			requestBody:      io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
-			setupStorageMock: func(card creditCard) {},
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusCreated,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
+			setupStorageMock: &cardStoreMock{
+				saveCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					if card.Brand != brandVisa || len(card.Fingerprint) == 0 {
+						return creditCard{}, assert.AnError
+					}
+					card.ID = 7
+					card.CreatedAt = testCardCreatedAt
+					card.Version = 1
+					return card, nil
+				},
+			},
+			expBody:     `{"id":7,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expLocation: "/cards/7",
+			expETag:     `"1"`,
+			expAudit: []auditEntry{
+				{
+					CardID:      7,
+					Action:      auditActionCreate,
+					After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+					Actor:       "ip:203.0.113.7",
+					CountryCode: uaCountryCode,
+				},
+			},
+		},
+		"read_only_fields_ignored": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusCreated,
+			requestBody:   io.NopCloser(strings.NewReader(`{"id":3,"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"Іванко","created_at":"2020-01-01T00:00:00Z","deleted_at":"2020-01-01T00:00:00Z"}`)),
+			setupStorageMock: &cardStoreMock{
+				saveCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					if card.ID != 0 || !card.CreatedAt.IsZero() || card.DeletedAt != nil {
+						return creditCard{}, assert.AnError
+					}
+					card.ID = 7
+					card.CreatedAt = testCardCreatedAt
+					card.Version = 1
+					return card, nil
+				},
+			},
+			expBody:     `{"id":7,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expLocation: "/cards/7",
+			expETag:     `"1"`,
+			expAudit: []auditEntry{
+				{
+					CardID:      7,
+					Action:      auditActionCreate,
+					After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+					Actor:       "ip:203.0.113.7",
+					CountryCode: uaCountryCode,
+				},
+			},
+		},
+		"card_brand_not_accepted": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusBadRequest,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"378282246310005","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"number": `card brand "amex" is not accepted`}),
+		},
+		"card_verification_failed": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusUnprocessableEntity,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":666,"holder":"Іванко"}`)),
+			expBody:       testProblem(problemCardVerificationFailed, "", nil),
+		},
+		"duplicate_card": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusConflict,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
+			setupStorageMock: &cardStoreMock{
+				saveCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, &duplicateCardError{ExistingID: 3}
+				},
+			},
+			expBody:     testProblem(problemDuplicateCard, "credit card 3 has the same number", nil),
+			expLocation: "/cards/3",
+		},
+		"internal_server_error": {
+			countryCode:   uaCountryCode,
+			expBody:       testProblem(problemInternalError, "", nil),
+			expStatusCode: http.StatusInternalServerError,
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":123,"holder":"Іванко"}`)),
+			setupStorageMock: &cardStoreMock{
+				saveCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, assert.AnError
+				},
+			},
 		},
 	}
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			request := http.Request{
-				Method: http.MethodPost,
-				Body:   tc.requestBody,
-				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+				Method:     http.MethodPost,
+				Body:       tc.requestBody,
+				Header:     http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+				RemoteAddr: "203.0.113.7:51234",
 			}
-
 			rw := httptest.NewRecorder()
-			createCard(tc.setupStorageMock)(rw, &request)
+			createCard(tc.setupStorageMock, newSimulatedCardVerifier([]int{666}), testBrandPolicy(t, brandVisa, brandMastercard), testFingerprinter(t))(rw, &request)
 
 			body := rw.Body.String()
 			assert.Equal(t, tc.expBody, body)
+			assert.Equal(t, tc.expLocation, rw.Header().Get("Location"))
+			assert.Equal(t, tc.expETag, rw.Header().Get("ETag"))
 			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAudit, mockedAudit(tc.setupStorageMock))
 		})
 	}
 }
 
-func Test_CardPut(t *testing.T) {
+func Test_CardGet(t *testing.T) {
 	testCases := map[string]struct {
-		setupStorageMock storageUpdateCardFunc
+		setupStorageMock CardStore
 		cardID           string
 		countryCode      string
-		requestBody      io.ReadCloser
 
 		expBody       string
+		expETag       string
 		expStatusCode int
+	}{
+		"invalid_path_param": {
+			countryCode:   uaCountryCode,
+			cardID:        "oleh",
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"success": {
+			countryCode: uaCountryCode,
+			cardID:      "2983",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{
+						ID:             id,
+						Number:         "4263982640269299",
+						Brand:          brandVisa,
+						ExpirationDate: testExpirationDate("12/43"),
+						Holder:         "Іванко",
+						CreatedAt:      testCardCreatedAt,
+						Version:        3,
+					}, nil
+				},
+			},
+			expBody:       `{"id":2983,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expETag:       `"3"`,
+			expStatusCode: http.StatusOK,
+		},
+		"record_not_found": {
+			countryCode: uaCountryCode,
+			cardID:      "5",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
+			},
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"internal_server_error": {
+			countryCode: uaCountryCode,
+			cardID:      "5",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, assert.AnError
+				},
+			},
+			expBody:       testProblem(problemInternalError, "", nil),
+			expStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := http.Request{
+				Method: http.MethodGet,
+				URL:    &url.URL{Path: "/cards/{id}"},
+				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+			}
+			request.SetPathValue("id", tc.cardID)
+
+			rw := httptest.NewRecorder()
+			getCard(tc.setupStorageMock)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expETag, rw.Header().Get("ETag"))
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+}
+
+func Test_CardPut(t *testing.T) {
+	const validBody = `{"number":"4263982640269299","expiration_date":"12/43","cvv":337,"holder":"Петро"}`
+
+	testCases := map[string]struct {
+		setupStorageMock CardStore
+		cardID           string
+		countryCode      string
+		ifMatch          string
+		requireIfMatch   bool
+		requestBody      io.ReadCloser
+
+		expBody         string
+		expETag         string
+		expStatusCode   int
+		expAuditActions []string
 	}{
 		"incorrect_id_type": {
 			cardID:        "yura",
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			requestBody:   nil,
+			expBody:       testProblem(problemMalformedRequest, "card id must be an integer", nil),
 			expStatusCode: http.StatusBadRequest,
 		},
 		"validation_errors": {
 			cardID:        "2",
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			requestBody:   io.NopCloser(strings.NewReader(`{"number":"9","expiration_date":"завтра","cvv":3,"holder":"А"}`)),
 			expStatusCode: http.StatusBadRequest,
-			expBody:       "cvv: must be no less than 100; expiration_date: дата не коректна; holder: the length must be between 5 and 50; number: must be a valid credit card number.",
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"cvv": "must be no less than 100", "expiration_date": "must be a valid expiration date", "holder": "the length must be between 5 and 50", "number": "must be a valid credit card number"}),
+		},
+		"card_brand_not_accepted": {
+			countryCode:   gbCountryCode,
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"6011111111111117","expiration_date":"12/43","cvv":337,"holder":"Петро"}`)),
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"number": `card brand "discover" is not accepted`}),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"card_verification_failed": {
+			countryCode:   gbCountryCode,
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":666,"holder":"Петро"}`)),
+			expBody:       testProblem(problemCardVerificationFailed, "", nil),
+			expStatusCode: http.StatusUnprocessableEntity,
 		},
 		"empty_body": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			cardID:        "1",
 			requestBody:   io.NopCloser(errMock{}),
+			expBody:       testProblem(problemMalformedRequest, "request body could not be read", nil),
 			expStatusCode: http.StatusBadRequest,
 		},
 		"invalid_json": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			cardID:        "1",
 			requestBody:   io.NopCloser(strings.NewReader("")),
+			expBody:       testProblem(problemMalformedRequest, "request body must be a JSON credit card", nil),
 			expStatusCode: http.StatusBadRequest,
 		},
 		"success": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
 			expStatusCode: http.StatusOK,
-			setupStorageMock: func(card creditCard) error {
-				return nil
+			setupStorageMock: &cardStoreMock{
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					if card.Version != 0 {
+						return creditCard{}, assert.AnError
+					}
+					card.Version = 4
+					return card, nil
+				},
 			},
-			cardID:      "2",
-			requestBody: io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":337,"holder":"Петро"}`)),
+			cardID:          "2",
+			requestBody:     io.NopCloser(strings.NewReader(validBody)),
+			expETag:         `"4"`,
+			expAuditActions: []string{auditActionUpdate},
 		},
 		"record_not_found": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
+			expBody:       testProblem(problemCardNotFound, "", nil),
 			expStatusCode: http.StatusNotFound,
-			setupStorageMock: func(card creditCard) error {
-				return errCreditCardNotFound
+			setupStorageMock: &cardStoreMock{
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
 			},
 			cardID:      "5",
-			requestBody: io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":337,"holder":"Петро"}`)),
+			requestBody: io.NopCloser(strings.NewReader(validBody)),
 		},
 		"internal_server_error": {
-			countryCode:   ukCountryCode,
+			countryCode:   gbCountryCode,
+			expBody:       testProblem(problemInternalError, "", nil),
 			expStatusCode: http.StatusInternalServerError,
-			setupStorageMock: func(card creditCard) error {
-				return assert.AnError
+			setupStorageMock: &cardStoreMock{
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, assert.AnError
+				},
 			},
 			cardID:      "5",
-			requestBody: io.NopCloser(strings.NewReader(`{"number":"4263982640269299","expiration_date":"12/43","cvv":337,"holder":"Петро"}`)),
+			requestBody: io.NopCloser(strings.NewReader(validBody)),
+		},
+		"if_match_required": {
+			countryCode:    gbCountryCode,
+			requireIfMatch: true,
+			cardID:         "2",
+			requestBody:    io.NopCloser(strings.NewReader(validBody)),
+			expBody:        testProblem(problemPreconditionRequired, "", nil),
+			expStatusCode:  http.StatusPreconditionRequired,
+		},
+		"if_match_matches_stored_version": {
+			countryCode:    gbCountryCode,
+			requireIfMatch: true,
+			ifMatch:        `"2", "3"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCardMock(3),
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					if card.Version != 3 {
+						return creditCard{}, assert.AnError
+					}
+					card.Version = 4
+					return card, nil
+				},
+			},
+			cardID:          "2",
+			requestBody:     io.NopCloser(strings.NewReader(validBody)),
+			expETag:         `"4"`,
+			expStatusCode:   http.StatusOK,
+			expAuditActions: []string{auditActionUpdate},
+		},
+		"if_match_stale_version": {
+			countryCode: gbCountryCode,
+			ifMatch:     `"2"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCardMock(3),
+			},
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(validBody)),
+			expBody:       testProblem(problemVersionConflict, "fetch the credit card again and retry", nil),
+			expStatusCode: http.StatusPreconditionFailed,
+		},
+		"if_match_weak_etag_never_matches": {
+			countryCode: gbCountryCode,
+			ifMatch:     `W/"3"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCardMock(3),
+			},
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(validBody)),
+			expBody:       testProblem(problemVersionConflict, "fetch the credit card again and retry", nil),
+			expStatusCode: http.StatusPreconditionFailed,
+		},
+		"if_match_lost_race": {
+			countryCode: gbCountryCode,
+			ifMatch:     `"3"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCardMock(3),
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, errVersionConflict
+				},
+			},
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(validBody)),
+			expBody:       testProblem(problemVersionConflict, "fetch the credit card again and retry", nil),
+			expStatusCode: http.StatusPreconditionFailed,
+		},
+		"if_match_record_not_found": {
+			countryCode: gbCountryCode,
+			ifMatch:     `"3"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
+			},
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(validBody)),
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"duplicate_card": {
+			countryCode: gbCountryCode,
+			setupStorageMock: &cardStoreMock{
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					return creditCard{}, &duplicateCardError{ExistingID: 5}
+				},
+			},
+			cardID:        "2",
+			requestBody:   io.NopCloser(strings.NewReader(validBody)),
+			expBody:       testProblem(problemDuplicateCard, "credit card 5 has the same number", nil),
+			expStatusCode: http.StatusConflict,
+		},
+		"if_match_any": {
+			countryCode:    gbCountryCode,
+			requireIfMatch: true,
+			ifMatch:        "*",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCardMock(3),
+				updateCardFunc: func(ctx context.Context, card creditCard) (creditCard, error) {
+					card.Version = 8
+					return card, nil
+				},
+			},
+			cardID:          "2",
+			requestBody:     io.NopCloser(strings.NewReader(validBody)),
+			expETag:         `"8"`,
+			expStatusCode:   http.StatusOK,
+			expAuditActions: []string{auditActionUpdate},
 		},
 	}
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			request := http.Request{
-				Method: http.MethodPut,
-				Body:   tc.requestBody,
-				URL:    &url.URL{Path: "/cards/{id}"},
-				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+				Method:     http.MethodPut,
+				Body:       tc.requestBody,
+				URL:        &url.URL{Path: "/cards/{id}"},
+				Header:     http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+				RemoteAddr: "203.0.113.7:51234",
+			}
+			if tc.ifMatch != "" {
+				request.Header.Set("If-Match", tc.ifMatch)
 			}
 
 			request.SetPathValue("id", tc.cardID)
 
 			rw := httptest.NewRecorder()
-			updateCard(tc.setupStorageMock)(rw, &request)
+			updateCard(tc.setupStorageMock, newSimulatedCardVerifier([]int{666}), testBrandPolicy(t, brandVisa, brandMastercard), testFingerprinter(t), tc.requireIfMatch)(rw, &request)
 
 			body := rw.Body.String()
 			assert.Equal(t, tc.expBody, body)
+			assert.Equal(t, tc.expETag, rw.Header().Get("ETag"))
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAuditActions, auditActions(mockedAudit(tc.setupStorageMock)))
+		})
+	}
+}
+
+func Test_CardPatch(t *testing.T) {
+	storedCard := func(ctx context.Context, owner string, id int) (creditCard, error) {
+		return creditCard{
+			ID:             id,
+			Number:         "4263982640269299",
+			Brand:          brandVisa,
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Іванко",
+			CreatedAt:      testCardCreatedAt,
+			Version:        3,
+		}, nil
+	}
+
+	testCases := map[string]struct {
+		setupStorageMock CardStore
+		cardID           string
+		contentType      string
+		ifMatch          string
+		requireIfMatch   bool
+		requestBody      string
+
+		expBody         string
+		expETag         string
+		expStatusCode   int
+		expAuditActions []string
+	}{
+		"incorrect_id_type": {
+			cardID:        "yura",
+			contentType:   mergePatchContentType,
+			expBody:       testProblem(problemMalformedRequest, "card id must be an integer", nil),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"if_match_required": {
+			cardID:         "2",
+			contentType:    mergePatchContentType,
+			requireIfMatch: true,
+			requestBody:    `{"holder":"Петро"}`,
+			expBody:        testProblem(problemPreconditionRequired, "", nil),
+			expStatusCode:  http.StatusPreconditionRequired,
+		},
+		"record_not_found": {
+			cardID:      "2",
+			contentType: mergePatchContentType,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
+			},
+			requestBody:   `{"holder":"Петро"}`,
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"stale_if_match": {
+			cardID:           "2",
+			contentType:      mergePatchContentType,
+			ifMatch:          `"2"`,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `{"holder":"Петро"}`,
+			expBody:          testProblem(problemVersionConflict, "fetch the credit card again and retry", nil),
+			expStatusCode:    http.StatusPreconditionFailed,
+		},
+		"unsupported_media_type": {
+			cardID:           "2",
+			contentType:      "application/json",
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `{"holder":"Петро"}`,
+			expBody:          testProblem(problemUnsupportedMediaType, "unsupported patch content type", nil),
+			expStatusCode:    http.StatusUnsupportedMediaType,
+		},
+		"patch_not_applicable": {
+			cardID:           "2",
+			contentType:      jsonPatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `[{"op":"remove","path":"/brand"}]`,
+			expBody:          testProblem(problemPatchNotApplicable, "patch cannot be applied to the credit card: error in remove for path: '/brand': unable to remove nonexistent key: brand: missing value", nil),
+			expStatusCode:    http.StatusUnprocessableEntity,
+		},
+		"copy_number_to_holder": {
+			cardID:           "2",
+			contentType:      jsonPatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `[{"op":"copy","from":"/number","path":"/holder"}]`,
+			expBody:          testProblem(problemPatchNotApplicable, "number is write-only and cannot be used in a copy operation", nil),
+			expStatusCode:    http.StatusUnprocessableEntity,
+		},
+		"test_number_matching": {
+			cardID:           "2",
+			contentType:      jsonPatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `[{"op":"test","path":"/number","value":"4263982640269299"}]`,
+			expBody:          testProblem(problemPatchNotApplicable, "number is write-only and cannot be used in a test operation", nil),
+			expStatusCode:    http.StatusUnprocessableEntity,
+		},
+		"test_number_mismatching": {
+			cardID:           "2",
+			contentType:      jsonPatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `[{"op":"test","path":"/number","value":"4263982640260000"}]`,
+			expBody:          testProblem(problemPatchNotApplicable, "number is write-only and cannot be used in a test operation", nil),
+			expStatusCode:    http.StatusUnprocessableEntity,
+		},
+		"invalid_result": {
+			cardID:           "2",
+			contentType:      mergePatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `{"holder":"П"}`,
+			expBody:          testProblem(problemValidationFailed, "", map[string]string{"holder": "the length must be between 5 and 50"}),
+			expStatusCode:    http.StatusBadRequest,
+		},
+		"number_change_requires_cvv": {
+			cardID:           "2",
+			contentType:      mergePatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `{"number":"5555555555554444"}`,
+			expBody:          testProblem(problemValidationFailed, "", map[string]string{"cvv": "cannot be blank"}),
+			expStatusCode:    http.StatusBadRequest,
+		},
+		"holder_only": {
+			cardID:      "2",
+			contentType: mergePatchContentType,
+			ifMatch:     `"3"`,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCard,
+				patchCardFunc: func(ctx context.Context, owner string, id, version int, patch cardPatch) (creditCard, error) {
+					if id != 2 || version != 3 || patch.Number != nil || patch.ExpirationDate != nil || patch.Holder == nil {
+						return creditCard{}, assert.AnError
+					}
+					card, _ := storedCard(ctx, owner, id)
+					card = patch.apply(card)
+					card.Version = 4
+					return card, nil
+				},
+			},
+			requestBody:     `{"holder":"Петро Петренко"}`,
+			expBody:         `{"id":2,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Петро Петренко","created_at":"2024-01-01T10:00:00Z"}`,
+			expETag:         `"4"`,
+			expStatusCode:   http.StatusOK,
+			expAuditActions: []string{auditActionUpdate},
+		},
+		"json_patch_number": {
+			cardID:      "2",
+			contentType: jsonPatchContentType,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCard,
+				patchCardFunc: func(ctx context.Context, owner string, id, version int, patch cardPatch) (creditCard, error) {
+					if patch.Number == nil || patch.Brand != brandMastercard || len(patch.Fingerprint) == 0 || patch.Holder != nil {
+						return creditCard{}, assert.AnError
+					}
+					card, _ := storedCard(ctx, owner, id)
+					card = patch.apply(card)
+					card.Version = 4
+					return card, nil
+				},
+			},
+			requestBody:     `[{"op":"replace","path":"/number","value":"5555555555554444"},{"op":"add","path":"/cvv","value":337}]`,
+			expBody:         `{"id":2,"number":"555555******4444","last4":"4444","brand":"mastercard","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expETag:         `"4"`,
+			expStatusCode:   http.StatusOK,
+			expAuditActions: []string{auditActionUpdate},
+		},
+		"no_changes": {
+			cardID:           "2",
+			contentType:      mergePatchContentType,
+			setupStorageMock: &cardStoreMock{getCardFunc: storedCard},
+			requestBody:      `{"expiration_date":"2043-12"}`,
+			expBody:          `{"id":2,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expETag:          `"3"`,
+			expStatusCode:    http.StatusOK,
+		},
+		"duplicate_card": {
+			cardID:      "2",
+			contentType: mergePatchContentType,
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: storedCard,
+				patchCardFunc: func(ctx context.Context, owner string, id, version int, patch cardPatch) (creditCard, error) {
+					return creditCard{}, &duplicateCardError{ExistingID: 5}
+				},
+			},
+			requestBody:   `{"number":"5555555555554444","cvv":337}`,
+			expBody:       testProblem(problemDuplicateCard, "credit card 5 has the same number", nil),
+			expStatusCode: http.StatusConflict,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := http.Request{
+				Method: http.MethodPatch,
+				Body:   io.NopCloser(strings.NewReader(tc.requestBody)),
+				URL:    &url.URL{Path: "/cards/{id}"},
+				Header: http.Header{
+					xCountryCodeHeaderKey: []string{uaCountryCode},
+					"Content-Type":        []string{tc.contentType},
+				},
+				RemoteAddr: "203.0.113.7:51234",
+			}
+			if tc.ifMatch != "" {
+				request.Header.Set("If-Match", tc.ifMatch)
+			}
+			request.SetPathValue("id", tc.cardID)
+
+			rw := httptest.NewRecorder()
+			patchCard(tc.setupStorageMock, newSimulatedCardVerifier([]int{666}), testBrandPolicy(t, brandVisa, brandMastercard), testFingerprinter(t), tc.requireIfMatch)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expETag, rw.Header().Get("ETag"))
 			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAuditActions, auditActions(mockedAudit(tc.setupStorageMock)))
 		})
 	}
 }
 
 func Test_CardDelete(t *testing.T) {
 	testCases := map[string]struct {
-		setupStorageMock storageDeleteCardFunc
+		setupStorageMock CardStore
 		cardID           string
 		countryCode      string
 		expStatusCode    int
+		expBody          string
+		expAuditActions  []string
 	}{
 		"invalid_path_param": {
 			countryCode:   uaCountryCode,
 			expStatusCode: http.StatusNotFound,
+			expBody:       testProblem(problemCardNotFound, "", nil),
 			cardID:        "oleh",
 		},
 		"success": {
-			countryCode:      uaCountryCode,
-			expStatusCode:    http.StatusNoContent,
-			cardID:           "12",
-			setupStorageMock: func(id int) {},
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusNoContent,
+			cardID:        "12",
+			setupStorageMock: &cardStoreMock{
+				deleteCardFunc: func(ctx context.Context, owner string, id int) error {
+					return nil
+				},
+			},
+			expAuditActions: []string{auditActionDelete},
+		},
+		"record_not_found": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusNotFound,
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			cardID:        "12",
+			setupStorageMock: &cardStoreMock{
+				deleteCardFunc: func(ctx context.Context, owner string, id int) error {
+					return errCreditCardNotFound
+				},
+			},
+		},
+		"internal_server_error": {
+			countryCode:   uaCountryCode,
+			expStatusCode: http.StatusInternalServerError,
+			expBody:       testProblem(problemInternalError, "", nil),
+			cardID:        "12",
+			setupStorageMock: &cardStoreMock{
+				deleteCardFunc: func(ctx context.Context, owner string, id int) error {
+					return assert.AnError
+				},
+			},
 		},
 	}
 
@@ -241,7 +948,401 @@ func Test_CardDelete(t *testing.T) {
 			rw := httptest.NewRecorder()
 			deleteCard(tc.setupStorageMock)(rw, &request)
 
-			assert.Empty(t, rw.Body.String())
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAuditActions, auditActions(mockedAudit(tc.setupStorageMock)))
+		})
+	}
+}
+
+var testCardCreatedAt = time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+func Test_CardRestore(t *testing.T) {
+	testCases := map[string]struct {
+		setupStorageMock CardStore
+		cardID           string
+		countryCode      string
+
+		expBody       string
+		expETag       string
+		expLocation   string
+		expStatusCode int
+	}{
+		"invalid_path_param": {
+			countryCode:   uaCountryCode,
+			cardID:        "oleh",
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"success": {
+			countryCode: uaCountryCode,
+			cardID:      "12",
+			setupStorageMock: &cardStoreMock{
+				restoreCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{
+						ID:             id,
+						Number:         "4263982640269299",
+						Brand:          brandVisa,
+						ExpirationDate: testExpirationDate("12/43"),
+						Holder:         "Іванко",
+						CreatedAt:      testCardCreatedAt,
+						Version:        3,
+					}, nil
+				},
+			},
+			expBody:       `{"id":12,"number":"426398******9299","last4":"9299","brand":"visa","expiration_date":"12/43","holder":"Іванко","created_at":"2024-01-01T10:00:00Z"}`,
+			expETag:       `"3"`,
+			expStatusCode: http.StatusOK,
+		},
+		"record_not_found": {
+			countryCode: uaCountryCode,
+			cardID:      "12",
+			setupStorageMock: &cardStoreMock{
+				restoreCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
+			},
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"card_not_deleted": {
+			countryCode: uaCountryCode,
+			cardID:      "12",
+			setupStorageMock: &cardStoreMock{
+				restoreCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardActive
+				},
+			},
+			expBody:       testProblem(problemCardNotDeleted, "", nil),
+			expStatusCode: http.StatusConflict,
+		},
+		"duplicate_card": {
+			countryCode: uaCountryCode,
+			cardID:      "12",
+			setupStorageMock: &cardStoreMock{
+				restoreCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, &duplicateCardError{ExistingID: 3}
+				},
+			},
+			expBody:       testProblem(problemDuplicateCard, "credit card 3 has the same number", nil),
+			expLocation:   "/cards/3",
+			expStatusCode: http.StatusConflict,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := http.Request{
+				Method: http.MethodPost,
+				URL:    &url.URL{Path: "/cards/{id}/restore"},
+				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+			}
+			request.SetPathValue("id", tc.cardID)
+
+			rw := httptest.NewRecorder()
+			restoreCard(tc.setupStorageMock)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expETag, rw.Header().Get("ETag"))
+			assert.Equal(t, tc.expLocation, rw.Header().Get("Location"))
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+}
+
+func Test_CardReveal(t *testing.T) {
+	testCases := map[string]struct {
+		setupStorageMock CardStore
+		cardID           string
+
+		expBody         string
+		expStatusCode   int
+		expAuditActions []string
+	}{
+		"invalid_path_param": {
+			cardID:        "oleh",
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"record_not_found": {
+			cardID: "12",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{}, errCreditCardNotFound
+				},
+			},
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"success": {
+			cardID: "12",
+			setupStorageMock: &cardStoreMock{
+				getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+					return creditCard{ID: id, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}, nil
+				},
+			},
+			expBody:         `{"id":12,"number":"4263982640269299"}`,
+			expStatusCode:   http.StatusOK,
+			expAuditActions: []string{auditActionReveal},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := http.Request{
+				Method: http.MethodPost,
+				URL:    &url.URL{Path: "/cards/{id}/reveal"},
+				Header: http.Header{xCountryCodeHeaderKey: []string{uaCountryCode}},
+			}
+			request.SetPathValue("id", tc.cardID)
+			audit := newTestAuditLog()
+
+			rw := httptest.NewRecorder()
+			revealCard(tc.setupStorageMock, audit)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAuditActions, auditActions(audit.entries))
+		})
+	}
+}
+
+func Test_RevealAuditWriteFailure(t *testing.T) {
+	store := &cardStoreMock{
+		getCardFunc: func(ctx context.Context, owner string, id int) (creditCard, error) {
+			return creditCard{ID: id, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко", Version: 1}, nil
+		},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/cards/12/reveal", nil)
+	request.SetPathValue("id", "12")
+
+	rw := httptest.NewRecorder()
+	revealCard(store, failingAuditLog{})(rw, request)
+
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.Equal(t, testProblem(problemInternalError, "", nil), rw.Body.String())
+}
+
+func Test_CardHistory(t *testing.T) {
+	testCases := map[string]struct {
+		cardID  string
+		entries []auditEntry
+
+		expBody       string
+		expStatusCode int
+	}{
+		"invalid_path_param": {
+			cardID:        "oleh",
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"no_history": {
+			cardID: "12",
+			entries: []auditEntry{
+				{CardID: 3, Action: auditActionCreate, Actor: "ip:203.0.113.7", CountryCode: uaCountryCode},
+			},
+			expBody:       testProblem(problemCardNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"success": {
+			cardID: "12",
+			entries: []auditEntry{
+				{
+					CardID:      12,
+					Action:      auditActionCreate,
+					After:       &auditCardSnapshot{Number: "426398******9299", ExpirationDate: "12/43", Holder: "Іванко", Version: 1},
+					Actor:       "ip:203.0.113.7",
+					CountryCode: uaCountryCode,
+				},
+				{CardID: 3, Action: auditActionCreate, Actor: "ip:203.0.113.7", CountryCode: uaCountryCode},
+			},
+			expBody:       `[{"id":1,"card_id":12,"action":"create","before":null,"after":{"number":"426398******9299","expiration_date":"12/43","holder":"Іванко","version":1},"actor":"ip:203.0.113.7","country_code":"UA","created_at":"2024-01-01T10:00:00Z"}]`,
+			expStatusCode: http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			audit := newTestAuditLog()
+			for _, entry := range tc.entries {
+				_, err := audit.RecordAudit(context.Background(), entry)
+				require.NoError(t, err)
+			}
+
+			request := http.Request{
+				Method: http.MethodGet,
+				URL:    &url.URL{Path: "/cards/{id}/history"},
+				Header: http.Header{xCountryCodeHeaderKey: []string{uaCountryCode}},
+			}
+			request.SetPathValue("id", tc.cardID)
+
+			rw := httptest.NewRecorder()
+			cardHistory(audit)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+}
+
+func Test_DuplicateCards(t *testing.T) {
+	testCases := map[string]struct {
+		setupStorageMock CardStore
+
+		expBody       string
+		expStatusCode int
+	}{
+		"success": {
+			setupStorageMock: &cardStoreMock{
+				listDuplicateCardsFunc: func(ctx context.Context) ([]duplicateCardGroup, error) {
+					return []duplicateCardGroup{{OwnerID: "alice", Last4: "9299", Brand: brandVisa, CardIDs: []int{2, 7}}}, nil
+				},
+			},
+			expBody:       `[{"owner_id":"alice","last4":"9299","brand":"visa","card_ids":[2,7]}]`,
+			expStatusCode: http.StatusOK,
+		},
+		"internal_server_error": {
+			setupStorageMock: &cardStoreMock{
+				listDuplicateCardsFunc: func(ctx context.Context) ([]duplicateCardGroup, error) {
+					return nil, assert.AnError
+				},
+			},
+			expBody:       testProblem(problemInternalError, "", nil),
+			expStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := http.Request{
+				Method: http.MethodGet,
+				URL:    &url.URL{Path: "/admin/cards/duplicates"},
+				Header: http.Header{xCountryCodeHeaderKey: []string{uaCountryCode}},
+			}
+
+			rw := httptest.NewRecorder()
+			listDuplicateCards(tc.setupStorageMock)(rw, &request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+}
+
+func Test_CreateAPIKey(t *testing.T) {
+	testCases := map[string]struct {
+		requestBody   string
+		cancelRequest bool
+
+		expBody       string
+		expStatusCode int
+	}{
+		"malformed_body": {
+			requestBody:   `{"name":`,
+			expBody:       testProblem(problemMalformedRequest, "request body must be a JSON API key request", nil),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"validation_failed": {
+			requestBody:   `{"name":"batch","owner_id":"svc","scopes":["cards:everything"]}`,
+			expBody:       testProblem(problemValidationFailed, "", map[string]string{"scopes": `unknown scope "cards:everything"`}),
+			expStatusCode: http.StatusBadRequest,
+		},
+		"request_cancelled": {
+			requestBody:   `{"name":"batch","owner_id":"svc","scopes":["cards:read"]}`,
+			cancelRequest: true,
+			expBody:       testProblem(problemRequestCancelled, "", nil),
+			expStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(tc.requestBody))
+			if tc.cancelRequest {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				request = request.WithContext(ctx)
+			}
+
+			rw := httptest.NewRecorder()
+			createAPIKey(newMemoryAPIKeyStore())(rw, request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		store := &memoryAPIKeyStore{revoked: make(map[int]bool), now: func() time.Time { return testCreatedAt(1) }}
+		request := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(`{"name":"batch","owner_id":"svc","scopes":["cards:read","cards:write"]}`))
+
+		rw := httptest.NewRecorder()
+		createAPIKey(store)(rw, request)
+
+		require.Equal(t, http.StatusCreated, rw.Code)
+		assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+
+		var created struct {
+			ID        int        `json:"id"`
+			Name      string     `json:"name"`
+			OwnerID   string     `json:"owner_id"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
+			CreatedAt time.Time  `json:"created_at"`
+			Key       string     `json:"key"`
+		}
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &created))
+		assert.Equal(t, 1, created.ID)
+		assert.Equal(t, "batch", created.Name)
+		assert.Equal(t, "svc", created.OwnerID)
+		assert.Equal(t, []string{scopeCardsRead, scopeCardsWrite}, created.Scopes)
+		assert.Nil(t, created.ExpiresAt)
+		assert.Equal(t, testCreatedAt(1), created.CreatedAt)
+		assert.NotContains(t, rw.Body.String(), "hash")
+
+		key, err := store.AuthenticateAPIKey(context.Background(), hashAPIKey(created.Key))
+		require.NoError(t, err)
+		assert.Equal(t, 1, key.ID)
+	})
+}
+
+func Test_RevokeAPIKey(t *testing.T) {
+	testCases := map[string]struct {
+		keyID string
+
+		expBody       string
+		expStatusCode int
+	}{
+		"success": {
+			keyID:         "1",
+			expStatusCode: http.StatusNoContent,
+		},
+		"not_found": {
+			keyID:         "42",
+			expBody:       testProblem(problemAPIKeyNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+		"invalid_path_param": {
+			keyID:         "batch",
+			expBody:       testProblem(problemAPIKeyNotFound, "", nil),
+			expStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			store := newMemoryAPIKeyStore()
+			_, err := store.CreateAPIKey(context.Background(), apiKey{Name: "batch", OwnerID: "svc", Scopes: []string{scopeCardsRead}, Hash: hashAPIKey("ck_batch")})
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodDelete, "/admin/api-keys/"+tc.keyID, nil)
+			request.SetPathValue("id", tc.keyID)
+
+			rw := httptest.NewRecorder()
+			revokeAPIKey(store)(rw, request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
 			assert.Equal(t, tc.expStatusCode, rw.Code)
 		})
 	}
@@ -253,3 +1354,112 @@ type errMock struct {
 func (e errMock) Read(p []byte) (n int, err error) {
 	return 0, assert.AnError
 }
+
+func storedCardMock(version int) func(ctx context.Context, owner string, id int) (creditCard, error) {
+	return func(ctx context.Context, owner string, id int) (creditCard, error) {
+		return creditCard{ID: id, Version: version}, nil
+	}
+}
+
+func newTestAuditLog() *memoryAuditLog {
+	audit := newMemoryAuditLog()
+	audit.now = func() time.Time { return testCardCreatedAt }
+	return audit
+}
+
+type failingAuditLog struct{}
+
+func (failingAuditLog) RecordAudit(ctx context.Context, entry auditEntry) (auditEntry, error) {
+	return auditEntry{}, assert.AnError
+}
+
+func (failingAuditLog) ListAudit(ctx context.Context, owner string, cardID int) ([]auditEntry, error) {
+	return nil, assert.AnError
+}
+
+func mockedAudit(store CardStore) []auditEntry {
+	mock, ok := store.(*cardStoreMock)
+	if !ok {
+		return nil
+	}
+	return mock.audit
+}
+
+func auditActions(entries []auditEntry) []string {
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+type cardStoreMock struct {
+	audit []auditEntry
+
+	saveCardFunc   func(ctx context.Context, card creditCard) (creditCard, error)
+	getCardFunc    func(ctx context.Context, owner string, id int) (creditCard, error)
+	listCardsFunc  func(ctx context.Context, query listCardsQuery) (cardsPage, error)
+	updateCardFunc func(ctx context.Context, card creditCard) (creditCard, error)
+	patchCardFunc  func(ctx context.Context, owner string, id, version int, patch cardPatch) (creditCard, error)
+	deleteCardFunc func(ctx context.Context, owner string, id int) error
+
+	restoreCardFunc        func(ctx context.Context, owner string, id int) (creditCard, error)
+	purgeDeletedCardsFunc  func(ctx context.Context, deletedBefore time.Time) (int, error)
+	listDuplicateCardsFunc func(ctx context.Context) ([]duplicateCardGroup, error)
+}
+
+func (m *cardStoreMock) SaveCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	saved, err := m.saveCardFunc(ctx, card)
+	if err == nil {
+		m.audit = append(m.audit, cardAuditEntry(audit, auditActionCreate, nil, &saved))
+	}
+	return saved, err
+}
+
+func (m *cardStoreMock) GetCard(ctx context.Context, owner string, id int) (creditCard, error) {
+	return m.getCardFunc(ctx, owner, id)
+}
+
+func (m *cardStoreMock) ListCards(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+	return m.listCardsFunc(ctx, query)
+}
+
+func (m *cardStoreMock) UpdateCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	updated, err := m.updateCardFunc(ctx, card)
+	if err == nil {
+		m.audit = append(m.audit, cardAuditEntry(audit, auditActionUpdate, nil, &updated))
+	}
+	return updated, err
+}
+
+func (m *cardStoreMock) PatchCard(ctx context.Context, owner string, id, version int, patch cardPatch, audit auditEntry) (creditCard, error) {
+	patched, err := m.patchCardFunc(ctx, owner, id, version, patch)
+	if err == nil {
+		m.audit = append(m.audit, cardAuditEntry(audit, auditActionUpdate, nil, &patched))
+	}
+	return patched, err
+}
+
+func (m *cardStoreMock) DeleteCard(ctx context.Context, owner string, id int, audit auditEntry) error {
+	err := m.deleteCardFunc(ctx, owner, id)
+	if err == nil {
+		m.audit = append(m.audit, cardAuditEntry(audit, auditActionDelete, &creditCard{ID: id, OwnerID: owner}, nil))
+	}
+	return err
+}
+
+func (m *cardStoreMock) RestoreCard(ctx context.Context, owner string, id int, audit auditEntry) (creditCard, error) {
+	restored, err := m.restoreCardFunc(ctx, owner, id)
+	if err == nil {
+		m.audit = append(m.audit, cardAuditEntry(audit, auditActionRestore, nil, &restored))
+	}
+	return restored, err
+}
+
+func (m *cardStoreMock) PurgeDeletedCards(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return m.purgeDeletedCardsFunc(ctx, deletedBefore)
+}
+
+func (m *cardStoreMock) ListDuplicateCards(ctx context.Context) ([]duplicateCardGroup, error) {
+	return m.listDuplicateCardsFunc(ctx)
+}
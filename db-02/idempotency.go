@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const idempotencyKeyHeaderKey = "Idempotency-Key"
+const idempotentReplayedHeaderKey = "Idempotent-Replayed"
+const maxIdempotencyKeyLength = 255
+
+type idempotentResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+type idempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Response    *idempotentResponse
+	CreatedAt   time.Time
+}
+
+type IdempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, key, requestHash string, expiredBefore time.Time) (idempotencyRecord, bool, error)
+	CompleteIdempotentRequest(ctx context.Context, key string, response idempotentResponse) error
+	ReleaseIdempotentRequest(ctx context.Context, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int, error)
+}
+
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *idempotencyRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *idempotencyRecorder) Write(p []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func idempotencyMiddleware(store IdempotencyStore, ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgIdempotencyKeyTooLong, maxIdempotencyKeyLength))
+			return
+		}
+
+		key = idempotencyStoreKey(r, key)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, problemMalformedRequest, localize(r, msgBodyUnreadable))
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := idempotencyRequestHash(r, body)
+		record, started, err := store.BeginIdempotentRequest(r.Context(), key, requestHash, time.Now().Add(-ttl))
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if !started {
+			replayIdempotentResponse(w, r, record, requestHash)
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		ctx := context.WithoutCancel(r.Context())
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+		if recorder.statusCode >= http.StatusInternalServerError {
+			if err := store.ReleaseIdempotentRequest(ctx, key); err != nil {
+				requestLogger(r).Error("release idempotency key", slog.Any("error", err))
+			}
+			return
+		}
+
+		err = store.CompleteIdempotentRequest(ctx, key, idempotentResponse{
+			StatusCode: recorder.statusCode,
+			Header:     w.Header().Clone(),
+			Body:       recorder.body.Bytes(),
+		})
+		if err != nil {
+			requestLogger(r).Error("complete idempotency key", slog.Any("error", err))
+		}
+	}
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, record idempotencyRecord, requestHash string) {
+	if record.RequestHash != requestHash {
+		writeProblem(w, r, problemIdempotencyKeyReused, "")
+		return
+	}
+
+	if record.Response == nil {
+		writeProblem(w, r, problemIdempotencyKeyInProgress, "")
+		return
+	}
+
+	for name, values := range record.Response.Header {
+		if name != http.CanonicalHeaderKey(xRequestIDHeaderKey) {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(idempotentReplayedHeaderKey, strconv.FormatBool(true))
+	w.WriteHeader(record.Response.StatusCode)
+	w.Write(record.Response.Body)
+}
+
+func idempotencyStoreKey(r *http.Request, key string) string {
+	subject := requestSubject(r)
+	if subject == "" {
+		return key
+	}
+
+	scope := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(scope[:]) + ":" + key
+}
+
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]idempotencyRecord
+	now     func() time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]idempotencyRecord), now: time.Now}
+}
+
+func (s *memoryIdempotencyStore) BeginIdempotentRequest(ctx context.Context, key, requestHash string, expiredBefore time.Time) (idempotencyRecord, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && !record.CreatedAt.Before(expiredBefore) {
+		return record, false, nil
+	}
+
+	record := idempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: s.now().UTC()}
+	s.records[key] = record
+
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotentRequest(ctx context.Context, key string, response idempotentResponse) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+
+	record.Response = &response
+	s.records[key] = record
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotentRequest(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Response == nil {
+		delete(s.records, key)
+	}
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for key, record := range s.records {
+		if record.CreatedAt.Before(createdBefore) {
+			delete(s.records, key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
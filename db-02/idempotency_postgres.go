@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type postgresIdempotencyStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func newPostgresIdempotencyStore(db *sql.DB) *postgresIdempotencyStore {
+	return &postgresIdempotencyStore{db: db, now: time.Now}
+}
+
+func (s *postgresIdempotencyStore) BeginIdempotentRequest(ctx context.Context, key, requestHash string, expiredBefore time.Time) (idempotencyRecord, bool, error) {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key=$1 AND created_at < $2", key, expiredBefore.UTC())
+	if err != nil {
+		return idempotencyRecord{}, false, fmt.Errorf("exec delete expired idempotency key: %w", err)
+	}
+
+	record := idempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: s.now().UTC()}
+	res, err := s.db.ExecContext(ctx, "INSERT INTO idempotency_keys(key, request_hash, created_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		record.Key, record.RequestHash, record.CreatedAt)
+	if err != nil {
+		return idempotencyRecord{}, false, fmt.Errorf("exec insert into idempotency keys: %w", err)
+	}
+
+	numRowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return idempotencyRecord{}, false, fmt.Errorf("rows affected on insert idempotency key: %w", err)
+	}
+	if numRowsAffected == 1 {
+		return record, true, nil
+	}
+
+	record, err = s.getIdempotencyRecord(ctx, key)
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	return record, false, nil
+}
+
+func (s *postgresIdempotencyStore) getIdempotencyRecord(ctx context.Context, key string) (idempotencyRecord, error) {
+	var record idempotencyRecord
+	var statusCode sql.NullInt64
+	var header sql.NullString
+	var body []byte
+	err := s.db.QueryRowContext(ctx, "SELECT key, request_hash, status_code, response_header, response_body, created_at FROM idempotency_keys WHERE key=$1", key).
+		Scan(&record.Key, &record.RequestHash, &statusCode, &header, &body, &record.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return idempotencyRecord{}, fmt.Errorf("idempotency key %q was released concurrently", key)
+	}
+	if err != nil {
+		return idempotencyRecord{}, fmt.Errorf("query idempotency key: %w", err)
+	}
+
+	record.CreatedAt = record.CreatedAt.UTC()
+	if statusCode.Valid {
+		record.Response = &idempotentResponse{StatusCode: int(statusCode.Int64), Header: http.Header{}, Body: body}
+		if err := json.Unmarshal([]byte(header.String), &record.Response.Header); err != nil {
+			return idempotencyRecord{}, fmt.Errorf("unmarshal idempotent response header: %w", err)
+		}
+	}
+
+	return record, nil
+}
+
+func (s *postgresIdempotencyStore) CompleteIdempotentRequest(ctx context.Context, key string, response idempotentResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return fmt.Errorf("marshal idempotent response header: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE idempotency_keys SET status_code=$1, response_header=$2, response_body=$3 WHERE key=$4",
+		response.StatusCode, string(header), response.Body, key)
+	if err != nil {
+		return fmt.Errorf("exec complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgresIdempotencyStore) ReleaseIdempotentRequest(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key=$1 AND status_code IS NULL", key)
+	if err != nil {
+		return fmt.Errorf("exec release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgresIdempotencyStore) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", createdBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("exec purge idempotency keys: %w", err)
+	}
+
+	numRowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected on purge idempotency keys: %w", err)
+	}
+
+	return int(numRowsAffected), nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type idempotencyStoreFactory func(t *testing.T) IdempotencyStore
+
+func idempotencyStoreBackends() map[string]idempotencyStoreFactory {
+	return map[string]idempotencyStoreFactory{
+		storageBackendMemory: func(t *testing.T) IdempotencyStore {
+			return &memoryIdempotencyStore{records: make(map[string]idempotencyRecord), now: newTestClock()}
+		},
+		storageBackendPostgres: func(t *testing.T) IdempotencyStore {
+			return &postgresIdempotencyStore{db: openTestDB(t), now: newTestClock()}
+		},
+	}
+}
+
+func TestIdempotencyStore_Lifecycle(t *testing.T) {
+	response := idempotentResponse{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Location": []string{"/cards/7"}, "Etag": []string{`"1"`}},
+		Body:       []byte(`{"id":7}`),
+	}
+
+	for backend, newStore := range idempotencyStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			record, started, err := store.BeginIdempotentRequest(ctx, "key-1", "hash-1", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.True(t, started)
+			assert.Equal(t, idempotencyRecord{Key: "key-1", RequestHash: "hash-1", CreatedAt: testCreatedAt(1)}, record)
+
+			record, started, err = store.BeginIdempotentRequest(ctx, "key-1", "hash-2", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.False(t, started)
+			assert.Equal(t, "hash-1", record.RequestHash)
+			assert.Nil(t, record.Response)
+
+			require.NoError(t, store.CompleteIdempotentRequest(ctx, "key-1", response))
+
+			record, started, err = store.BeginIdempotentRequest(ctx, "key-1", "hash-1", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.False(t, started)
+			assert.Equal(t, &response, record.Response)
+
+			require.NoError(t, store.ReleaseIdempotentRequest(ctx, "key-1"))
+			_, started, err = store.BeginIdempotentRequest(ctx, "key-1", "hash-1", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.False(t, started)
+
+			_, started, err = store.BeginIdempotentRequest(ctx, "key-2", "hash-2", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.True(t, started)
+
+			require.NoError(t, store.ReleaseIdempotentRequest(ctx, "key-2"))
+			_, started, err = store.BeginIdempotentRequest(ctx, "key-2", "hash-3", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.True(t, started)
+
+			record, started, err = store.BeginIdempotentRequest(ctx, "key-1", "hash-4", testCreatedAt(2))
+			require.NoError(t, err)
+			assert.True(t, started)
+			assert.Equal(t, "hash-4", record.RequestHash)
+		})
+	}
+}
+
+func TestIdempotencyStore_PurgeIdempotencyKeys(t *testing.T) {
+	for backend, newStore := range idempotencyStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			for _, key := range []string{"key-1", "key-2", "key-3"} {
+				_, _, err := store.BeginIdempotentRequest(ctx, key, "hash", testCreatedAt(0))
+				require.NoError(t, err)
+			}
+
+			purged, err := store.PurgeIdempotencyKeys(ctx, testCreatedAt(3))
+			require.NoError(t, err)
+			assert.Equal(t, 2, purged)
+
+			_, started, err := store.BeginIdempotentRequest(ctx, "key-3", "other-hash", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.False(t, started)
+
+			_, started, err = store.BeginIdempotentRequest(ctx, "key-1", "other-hash", testCreatedAt(0))
+			require.NoError(t, err)
+			assert.True(t, started)
+		})
+	}
+}
+
+func Test_idempotencyMiddleware(t *testing.T) {
+	type attempt struct {
+		method string
+		path   string
+		key    string
+		body   string
+
+		expStatusCode int
+		expBody       string
+		expLocation   string
+		expReplayed   string
+	}
+
+	testCases := map[string]struct {
+		handlerStatus []int
+		attempts      []attempt
+		expCalls      int
+	}{
+		"no_key": {
+			handlerStatus: []int{http.StatusCreated, http.StatusCreated},
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodPost, path: "/cards", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 2", expLocation: "/cards/2"},
+			},
+			expCalls: 2,
+		},
+		"replayed": {
+			handlerStatus: []int{http.StatusCreated},
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 1", expLocation: "/cards/1", expReplayed: "true"},
+			},
+			expCalls: 1,
+		},
+		"client_errors_are_replayed": {
+			handlerStatus: []int{http.StatusBadRequest},
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{}`, expStatusCode: http.StatusBadRequest, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{}`, expStatusCode: http.StatusBadRequest, expBody: "call 1", expLocation: "/cards/1", expReplayed: "true"},
+			},
+			expCalls: 1,
+		},
+		"different_body": {
+			handlerStatus: []int{http.StatusCreated},
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Петро"}`, expStatusCode: http.StatusUnprocessableEntity, expBody: testProblem(problemIdempotencyKeyReused, "", nil)},
+			},
+			expCalls: 1,
+		},
+		"different_route": {
+			handlerStatus: []int{http.StatusNoContent},
+			attempts: []attempt{
+				{method: http.MethodDelete, path: "/cards/1", key: "retry-1", expStatusCode: http.StatusNoContent, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodDelete, path: "/cards/2", key: "retry-1", expStatusCode: http.StatusUnprocessableEntity, expBody: testProblem(problemIdempotencyKeyReused, "", nil)},
+			},
+			expCalls: 1,
+		},
+		"server_errors_are_retried": {
+			handlerStatus: []int{http.StatusGatewayTimeout, http.StatusCreated},
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusGatewayTimeout, expBody: "call 1", expLocation: "/cards/1"},
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 2", expLocation: "/cards/2"},
+				{method: http.MethodPost, path: "/cards", key: "retry-1", body: `{"holder":"Іванко"}`, expStatusCode: http.StatusCreated, expBody: "call 2", expLocation: "/cards/2", expReplayed: "true"},
+			},
+			expCalls: 2,
+		},
+		"key_too_long": {
+			attempts: []attempt{
+				{method: http.MethodPost, path: "/cards", key: strings.Repeat("k", 256), expStatusCode: http.StatusBadRequest, expBody: testProblem(problemMalformedRequest, "Idempotency-Key must be at most 255 characters", nil)},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			next := func(w http.ResponseWriter, r *http.Request) {
+				_, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				calls++
+				w.Header().Set("Location", "/cards/"+strconv.Itoa(calls))
+				w.WriteHeader(tc.handlerStatus[calls-1])
+				w.Write([]byte("call " + strconv.Itoa(calls)))
+			}
+
+			handler := idempotencyMiddleware(newMemoryIdempotencyStore(), time.Hour, next)
+			for _, attempt := range tc.attempts {
+				request := httptest.NewRequest(attempt.method, attempt.path, strings.NewReader(attempt.body))
+				if attempt.key != "" {
+					request.Header.Set(idempotencyKeyHeaderKey, attempt.key)
+				}
+
+				rw := httptest.NewRecorder()
+				handler(rw, request)
+
+				assert.Equal(t, attempt.expStatusCode, rw.Code)
+				assert.Equal(t, attempt.expBody, rw.Body.String())
+				assert.Equal(t, attempt.expLocation, rw.Header().Get("Location"))
+				assert.Equal(t, attempt.expReplayed, rw.Header().Get(idempotentReplayedHeaderKey))
+			}
+			assert.Equal(t, tc.expCalls, calls)
+		})
+	}
+}
+
+func Test_idempotencyMiddleware_inProgress(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	_, _, err := store.BeginIdempotentRequest(context.Background(), "retry-1", idempotencyRequestHash(httptest.NewRequest(http.MethodPost, "/cards", nil), []byte(`{}`)), time.Time{})
+	require.NoError(t, err)
+
+	handler := idempotencyMiddleware(store, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not run while the key is in progress")
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/cards", strings.NewReader(`{}`))
+	request.Header.Set(idempotencyKeyHeaderKey, "retry-1")
+
+	rw := httptest.NewRecorder()
+	handler(rw, request)
+
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	assert.Equal(t, testProblem(problemIdempotencyKeyInProgress, "", nil), rw.Body.String())
+}
+
+func Test_idempotencyMiddleware_scopedBySubject(t *testing.T) {
+	calls := 0
+	handler := idempotencyMiddleware(newMemoryIdempotencyStore(), time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(requestSubject(r)))
+	})
+
+	for _, subject := range []string{"alice", "bob", "alice"} {
+		request := httptest.NewRequest(http.MethodPost, "/cards", strings.NewReader(`{}`))
+		request = withPrincipal(request, principal{Subject: subject})
+		request.Header.Set(idempotencyKeyHeaderKey, "create-1")
+
+		rw := httptest.NewRecorder()
+		handler(rw, request)
+
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Equal(t, subject, rw.Body.String())
+	}
+	assert.Equal(t, 2, calls)
+}
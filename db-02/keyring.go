@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const dataKeySize = 32
+
+var errUnknownKeyID = errors.New("unknown key id")
+
+type keyringFile struct {
+	ActiveKeyID string `json:"active_key_id"`
+	Keys        []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+type keyring struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+type encryptedPAN struct {
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+}
+
+func loadKeyring(path string) (*keyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("decode keyring: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for _, key := range file.Keys {
+		material, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("decode key %q: %w", key.ID, err)
+		}
+		keys[key.ID] = material
+	}
+
+	return newKeyring(file.ActiveKeyID, keys)
+}
+
+func newKeyring(activeKeyID string, keys map[string][]byte) (*keyring, error) {
+	k := &keyring{activeKeyID: activeKeyID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, material := range keys {
+		if id == "" {
+			return nil, errors.New("key id must not be empty")
+		}
+		if len(material) != dataKeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, dataKeySize, len(material))
+		}
+
+		aead, err := newAEAD(material)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+
+	if _, ok := k.keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeKeyID)
+	}
+
+	return k, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (k *keyring) encryptPAN(pan string) (encryptedPAN, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return encryptedPAN{}, fmt.Errorf("generate data key: %w", err)
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return encryptedPAN{}, fmt.Errorf("init data key: %w", err)
+	}
+
+	ciphertext, err := seal(dataAEAD, []byte(pan), nil)
+	if err != nil {
+		return encryptedPAN{}, fmt.Errorf("encrypt pan: %w", err)
+	}
+
+	wrappedKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+	if err != nil {
+		return encryptedPAN{}, fmt.Errorf("wrap data key: %w", err)
+	}
+
+	return encryptedPAN{KeyID: k.activeKeyID, DataKey: wrappedKey, Ciphertext: ciphertext}, nil
+}
+
+func (k *keyring) decryptPAN(pan encryptedPAN) (string, error) {
+	dataKey, err := k.unwrapDataKey(pan)
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("init data key: %w", err)
+	}
+
+	plaintext, err := unseal(dataAEAD, pan.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt pan: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func (k *keyring) rewrapPAN(pan encryptedPAN) (encryptedPAN, error) {
+	dataKey, err := k.unwrapDataKey(pan)
+	if err != nil {
+		return encryptedPAN{}, err
+	}
+
+	wrappedKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+	if err != nil {
+		return encryptedPAN{}, fmt.Errorf("wrap data key: %w", err)
+	}
+
+	return encryptedPAN{KeyID: k.activeKeyID, DataKey: wrappedKey, Ciphertext: pan.Ciphertext}, nil
+}
+
+func (k *keyring) unwrapDataKey(pan encryptedPAN) ([]byte, error) {
+	keyAEAD, ok := k.keys[pan.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownKeyID, pan.KeyID)
+	}
+
+	dataKey, err := unseal(keyAEAD, pan.DataKey, []byte(pan.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func unseal(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
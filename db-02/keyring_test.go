@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKeyMaterial(id string) []byte {
+	return bytes.Repeat([]byte(id[len(id)-1:]), dataKeySize)
+}
+
+func newTestKeyring(t *testing.T, activeKeyID string, keyIDs ...string) *keyring {
+	t.Helper()
+
+	keys := map[string][]byte{activeKeyID: testKeyMaterial(activeKeyID)}
+	for _, id := range keyIDs {
+		keys[id] = testKeyMaterial(id)
+	}
+
+	k, err := newKeyring(activeKeyID, keys)
+	require.NoError(t, err)
+
+	return k
+}
+
+func Test_loadKeyring(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		expErr  string
+	}{
+		"ok": {
+			content: `{"active_key_id":"k2","keys":[{"id":"k1","key":"MTExMTExMTExMTExMTExMTExMTExMTExMTExMTExMTE="},{"id":"k2","key":"MjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjI="}]}`,
+		},
+		"invalid_json": {
+			content: `{`,
+			expErr:  "decode keyring: unexpected end of JSON input",
+		},
+		"invalid_base64": {
+			content: `{"active_key_id":"k1","keys":[{"id":"k1","key":"!"}]}`,
+			expErr:  `decode key "k1": illegal base64 data at input byte 0`,
+		},
+		"short_key": {
+			content: `{"active_key_id":"k1","keys":[{"id":"k1","key":"MTEx"}]}`,
+			expErr:  `key "k1" must be 32 bytes, got 3`,
+		},
+		"empty_key_id": {
+			content: `{"active_key_id":"k1","keys":[{"id":"","key":"MTExMTExMTExMTExMTExMTExMTExMTExMTExMTExMTE="}]}`,
+			expErr:  "key id must not be empty",
+		},
+		"missing_active_key": {
+			content: `{"active_key_id":"k3","keys":[{"id":"k1","key":"MTExMTExMTExMTExMTExMTExMTExMTExMTExMTExMTE="}]}`,
+			expErr:  `active key "k3" is not in the keyring`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			k, err := loadKeyring(path)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "k2", k.activeKeyID)
+			assert.Len(t, k.keys, 2)
+		})
+	}
+}
+
+func Test_keyringEncryptDecryptPAN(t *testing.T) {
+	k := newTestKeyring(t, "k1")
+
+	pan, err := k.encryptPAN("4263982640269299")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", pan.KeyID)
+	assert.NotContains(t, string(pan.Ciphertext), "4263982640269299")
+
+	again, err := k.encryptPAN("4263982640269299")
+	require.NoError(t, err)
+	assert.NotEqual(t, pan.Ciphertext, again.Ciphertext)
+
+	number, err := k.decryptPAN(pan)
+	require.NoError(t, err)
+	assert.Equal(t, "4263982640269299", number)
+
+	tampered := pan
+	tampered.Ciphertext = bytes.Clone(pan.Ciphertext)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+	_, err = k.decryptPAN(tampered)
+	assert.ErrorContains(t, err, "decrypt pan")
+
+	relabelled := pan
+	relabelled.KeyID = "k2"
+	_, err = newTestKeyring(t, "k1", "k2").decryptPAN(relabelled)
+	assert.ErrorContains(t, err, "unwrap data key")
+
+	_, err = newTestKeyring(t, "k2").decryptPAN(pan)
+	assert.ErrorIs(t, err, errUnknownKeyID)
+}
+
+func Test_keyringRewrapPAN(t *testing.T) {
+	pan, err := newTestKeyring(t, "k1").encryptPAN("4263982640269299")
+	require.NoError(t, err)
+
+	rotated := newTestKeyring(t, "k2", "k1")
+	rewrapped, err := rotated.rewrapPAN(pan)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", rewrapped.KeyID)
+	assert.Equal(t, pan.Ciphertext, rewrapped.Ciphertext)
+
+	number, err := newTestKeyring(t, "k2").decryptPAN(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "4263982640269299", number)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+type keyRotationRow struct {
+	id      int
+	version int
+	number  sql.NullString
+	pan     encryptedPAN
+}
+
+func (s *postgresCardStore) CountPlaintextCards(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM credit_cards WHERE number IS NOT NULL").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count plaintext credit cards: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s *postgresCardStore) RotateKeys(ctx context.Context, batchSize int) (int, error) {
+	rotated := 0
+	lastID := 0
+	for {
+		batch, err := s.keyRotationBatch(ctx, lastID, batchSize)
+		if err != nil {
+			return rotated, err
+		}
+		if len(batch) == 0 {
+			return rotated, nil
+		}
+
+		n, err := s.rotateBatch(ctx, batch)
+		rotated += n
+		if err != nil {
+			return rotated, err
+		}
+		s.logger.Debug("re-encrypted credit card batch", slog.Int("count", n), slog.Int("last_id", batch[len(batch)-1].id))
+
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func (s *postgresCardStore) keyRotationBatch(ctx context.Context, afterID, batchSize int) ([]keyRotationRow, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, version, number, number_ciphertext, number_data_key, number_key_id FROM credit_cards WHERE id > $1 AND (number_key_id IS NULL OR number_key_id <> $2) ORDER BY id LIMIT $3",
+		afterID, s.keyring.activeKeyID, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query credit cards to rotate: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []keyRotationRow
+	for rows.Next() {
+		var row keyRotationRow
+		var keyID sql.NullString
+		err := rows.Scan(&row.id, &row.version, &row.number, &row.pan.Ciphertext, &row.pan.DataKey, &keyID)
+		if err != nil {
+			return nil, fmt.Errorf("scan credit card to rotate: %w", err)
+		}
+		row.pan.KeyID = keyID.String
+
+		batch = append(batch, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate credit cards to rotate: %w", err)
+	}
+
+	return batch, nil
+}
+
+func (s *postgresCardStore) rotateBatch(ctx context.Context, batch []keyRotationRow) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin key rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	rotated := 0
+	for _, row := range batch {
+		var pan encryptedPAN
+		if row.pan.KeyID == "" {
+			pan, err = s.keyring.encryptPAN(row.number.String)
+		} else {
+			pan, err = s.keyring.rewrapPAN(row.pan)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("credit card %d: %w", row.id, err)
+		}
+
+		res, err := tx.ExecContext(ctx, "UPDATE credit_cards SET number=NULL, number_ciphertext=$1, number_data_key=$2, number_key_id=$3 WHERE id=$4 AND version=$5",
+			pan.Ciphertext, pan.DataKey, pan.KeyID, row.id, row.version)
+		if err != nil {
+			return 0, fmt.Errorf("exec rotate credit card %d: %w", row.id, err)
+		}
+
+		numRowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("rows affected on rotate: %w", err)
+		}
+		rotated += int(numRowsAffected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit key rotation: %w", err)
+	}
+
+	return rotated, nil
+}
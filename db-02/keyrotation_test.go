@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresCardStore_RotateKeys(t *testing.T) {
+	testCases := map[string]struct {
+		batchSize  int
+		expRotated int
+	}{
+		"single_batch":    {batchSize: 10, expRotated: 4},
+		"several_batches": {batchSize: 1, expRotated: 4},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db := openTestDB(t)
+
+			old := &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+			seedCards(t, old, []creditCard{
+				{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"},
+				{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро"},
+				{Number: "378282246310005", ExpirationDate: testExpirationDate("02/31"), Holder: "Оксана"},
+			})
+			_, err := db.Exec("INSERT INTO credit_cards(number, expiration_date, holder_name, created_at, brand, last4) VALUES ('9804000000000000', ?, 'Марія', ?, 'prostir', '0000')", testExpirationDate("03/32").Time(), testCreatedAt(4))
+			require.NoError(t, err)
+
+			store := &postgresCardStore{db: db, keyring: newTestKeyring(t, "k2", "k1"), logger: discardLogger, now: newTestClock()}
+			expCards := listAllCards(t, store)
+
+			plaintext, err := store.CountPlaintextCards(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 1, plaintext)
+			assert.Error(t, checkPlaintextCards(store))
+
+			rotated, err := store.RotateKeys(context.Background(), tc.batchSize)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expRotated, rotated)
+			assert.NoError(t, checkPlaintextCards(store))
+
+			rows, err := db.Query("SELECT number, number_key_id FROM credit_cards ORDER BY id")
+			require.NoError(t, err)
+			defer rows.Close()
+			for rows.Next() {
+				var number, keyID sql.NullString
+				require.NoError(t, rows.Scan(&number, &keyID))
+				assert.False(t, number.Valid)
+				assert.Equal(t, "k2", keyID.String)
+			}
+			require.NoError(t, rows.Err())
+
+			onlyNewKey := &postgresCardStore{db: db, keyring: newTestKeyring(t, "k2"), logger: discardLogger, now: newTestClock()}
+			assert.Equal(t, expCards, listAllCards(t, onlyNewKey))
+
+			rotated, err = store.RotateKeys(context.Background(), tc.batchSize)
+			require.NoError(t, err)
+			assert.Zero(t, rotated)
+		})
+	}
+}
+
+func TestPostgresCardStore_NumberEncryptedAtRest(t *testing.T) {
+	db := openTestDB(t)
+	store := &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+
+	card, err := store.SaveCard(context.Background(), creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}, auditEntry{})
+	require.NoError(t, err)
+
+	var number sql.NullString
+	var ciphertext []byte
+	var keyID string
+	err = db.QueryRow("SELECT number, number_ciphertext, number_key_id FROM credit_cards WHERE id=?", card.ID).Scan(&number, &ciphertext, &keyID)
+	require.NoError(t, err)
+	assert.False(t, number.Valid)
+	assert.NotContains(t, string(ciphertext), "4263982640269299")
+	assert.Equal(t, "k1", keyID)
+
+	_, err = (&postgresCardStore{db: db, keyring: newTestKeyring(t, "k2"), logger: discardLogger, now: newTestClock()}).GetCard(context.Background(), "", card.ID)
+	assert.ErrorIs(t, err, errUnknownKeyID)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const acceptLanguageHeaderKey = "Accept-Language"
+
+const (
+	languageUkrainian = "uk"
+	languageEnglish   = "en"
+)
+
+const defaultLanguage = languageEnglish
+
+var messageCatalogs = map[string]map[messageKey]string{
+	languageUkrainian: ukrainianMessages,
+	languageEnglish:   englishMessages,
+}
+
+type languageContextKey struct{}
+
+func languageMiddleware(fallback string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lang := negotiateLanguage(r.Header.Get(acceptLanguageHeaderKey), fallback)
+
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", acceptLanguageHeaderKey)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), languageContextKey{}, lang)))
+	}
+}
+
+func requestLanguage(r *http.Request) string {
+	lang, ok := r.Context().Value(languageContextKey{}).(string)
+	if !ok {
+		return defaultLanguage
+	}
+
+	return lang
+}
+
+func isSupportedLanguage(lang string) bool {
+	_, ok := messageCatalogs[lang]
+	return ok
+}
+
+func negotiateLanguage(header, fallback string) string {
+	type languageRange struct {
+		tag    string
+		weight float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, languageRange{tag: tag, weight: weight})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].weight > ranges[j].weight
+	})
+
+	for _, lr := range ranges {
+		if lr.tag == "*" {
+			return fallback
+		}
+
+		primary, _, _ := strings.Cut(lr.tag, "-")
+		if isSupportedLanguage(primary) {
+			return primary
+		}
+	}
+
+	return fallback
+}
+
+func translate(lang string, key messageKey, args ...any) string {
+	message, ok := messageCatalogs[lang][key]
+	if !ok {
+		message, ok = messageCatalogs[defaultLanguage][key]
+	}
+	if !ok {
+		return string(key)
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+
+	return fmt.Sprintf(message, args...)
+}
+
+func localize(r *http.Request, key messageKey, args ...any) string {
+	return translate(requestLanguage(r), key, args...)
+}
+
+type localizedError struct {
+	Key  messageKey
+	Args []any
+	Err  error
+}
+
+func newLocalizedError(key messageKey, args ...any) *localizedError {
+	return &localizedError{Key: key, Args: args}
+}
+
+func wrapLocalizedError(err error, key messageKey, args ...any) *localizedError {
+	return &localizedError{Key: key, Args: args, Err: err}
+}
+
+func (e *localizedError) Error() string {
+	return translate(languageEnglish, e.Key, e.Args...)
+}
+
+func (e *localizedError) Unwrap() error {
+	return e.Err
+}
+
+func localizeError(r *http.Request, err error) string {
+	var localized *localizedError
+	if errors.As(err, &localized) {
+		return localize(r, localized.Key, localized.Args...)
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_negotiateLanguage(t *testing.T) {
+	testCases := map[string]struct {
+		header   string
+		fallback string
+		expLang  string
+	}{
+		"empty_header": {
+			header:   "",
+			fallback: languageUkrainian,
+			expLang:  languageUkrainian,
+		},
+		"exact_match": {
+			header:   "en",
+			fallback: languageUkrainian,
+			expLang:  languageEnglish,
+		},
+		"region_subtag": {
+			header:   "uk-UA",
+			fallback: languageEnglish,
+			expLang:  languageUkrainian,
+		},
+		"case_insensitive": {
+			header:   "EN-gb",
+			fallback: languageUkrainian,
+			expLang:  languageEnglish,
+		},
+		"highest_weight_wins": {
+			header:   "en;q=0.5, uk;q=0.9",
+			fallback: languageEnglish,
+			expLang:  languageUkrainian,
+		},
+		"skips_unsupported": {
+			header:   "de-DE, fr;q=0.9, en;q=0.1",
+			fallback: languageUkrainian,
+			expLang:  languageEnglish,
+		},
+		"zero_weight_excluded": {
+			header:   "uk;q=0",
+			fallback: languageEnglish,
+			expLang:  languageEnglish,
+		},
+		"wildcard_uses_fallback": {
+			header:   "de, *;q=0.5",
+			fallback: languageUkrainian,
+			expLang:  languageUkrainian,
+		},
+		"malformed_weight_ignored": {
+			header:   "en;q=high, uk;q=0.2",
+			fallback: languageEnglish,
+			expLang:  languageUkrainian,
+		},
+		"nothing_supported": {
+			header:   "de, fr",
+			fallback: languageEnglish,
+			expLang:  languageEnglish,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expLang, negotiateLanguage(tc.header, tc.fallback))
+		})
+	}
+}
+
+func Test_languageMiddleware(t *testing.T) {
+	testCases := map[string]struct {
+		acceptLanguage string
+		fallback       string
+
+		expLang string
+		expBody string
+	}{
+		"ukrainian": {
+			acceptLanguage: "uk-UA,uk;q=0.9",
+			fallback:       languageEnglish,
+			expLang:        languageUkrainian,
+			expBody:        `{"type":"/problems/card-not-found","title":"Картку не знайдено","status":404,"detail":"ідентифікатор картки має бути цілим числом"}`,
+		},
+		"english": {
+			acceptLanguage: "en-US",
+			fallback:       languageUkrainian,
+			expLang:        languageEnglish,
+			expBody:        `{"type":"/problems/card-not-found","title":"Credit card not found","status":404,"detail":"card id must be an integer"}`,
+		},
+		"fallback": {
+			acceptLanguage: "pl",
+			fallback:       languageUkrainian,
+			expLang:        languageUkrainian,
+			expBody:        `{"type":"/problems/card-not-found","title":"Картку не знайдено","status":404,"detail":"ідентифікатор картки має бути цілим числом"}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			next := func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, r, problemCardNotFound, localize(r, msgCardIDNotInteger))
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/cards/oleh", nil)
+			request.Header.Set(acceptLanguageHeaderKey, tc.acceptLanguage)
+
+			rw := httptest.NewRecorder()
+			languageMiddleware(tc.fallback, next)(rw, request)
+
+			assert.Equal(t, tc.expLang, rw.Header().Get("Content-Language"))
+			assert.Equal(t, acceptLanguageHeaderKey, rw.Header().Get("Vary"))
+			assert.Equal(t, tc.expBody, rw.Body.String())
+		})
+	}
+}
+
+func Test_validateLocalized(t *testing.T) {
+	req := cardRequest{
+		Number:         "42",
+		ExpirationDate: "13/43",
+		Holder:         "Iva",
+		CvvCode:        12,
+	}
+
+	testCases := map[string]struct {
+		lang      string
+		expErrors map[string]string
+	}{
+		"ukrainian": {
+			lang: languageUkrainian,
+			expErrors: map[string]string{
+				"cvv":             "має бути не менше 100",
+				"expiration_date": "дата не коректна",
+				"holder":          "довжина має бути від 5 до 50 символів",
+				"number":          "має бути дійсним номером картки",
+			},
+		},
+		"english": {
+			lang: languageEnglish,
+			expErrors: map[string]string{
+				"cvv":             "must be no less than 100",
+				"expiration_date": "must be a valid expiration date",
+				"holder":          "the length must be between 5 and 50",
+				"number":          "must be a valid credit card number",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validate(tc.lang, req, true)
+
+			fieldErrors := make(map[string]string)
+			for field, fieldErr := range err.(validation.Errors) {
+				fieldErrors[field] = fieldErr.Error()
+			}
+			assert.Equal(t, tc.expErrors, fieldErrors)
+		})
+	}
+}
+
+func Test_localizeError(t *testing.T) {
+	testCases := map[string]struct {
+		err     error
+		lang    string
+		expText string
+	}{
+		"localized_ukrainian": {
+			err:     newLocalizedError(msgQueryLimitOutOfRange, maxPageLimit),
+			lang:    languageUkrainian,
+			expText: "limit має бути від 1 до 100",
+		},
+		"localized_english": {
+			err:     newLocalizedError(msgQueryUnknownSortField, "number"),
+			lang:    languageEnglish,
+			expText: `unknown sort field "number"`,
+		},
+		"wrapped_cause": {
+			err:     wrapLocalizedError(errPatchNotApplicable, msgPatchNotApplicable, errors.New("test failed")),
+			lang:    languageUkrainian,
+			expText: "зміну неможливо застосувати до картки: test failed",
+		},
+		"plain_error": {
+			err:     errors.New("boom"),
+			lang:    languageUkrainian,
+			expText: "boom",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			next := func(w http.ResponseWriter, r *http.Request) {
+				got = localizeError(r, tc.err)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			request.Header.Set(acceptLanguageHeaderKey, tc.lang)
+
+			languageMiddleware(languageEnglish, next)(httptest.NewRecorder(), request)
+			assert.Equal(t, tc.expText, got)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	appEnvProduction  = "production"
+	appEnvDevelopment = "development"
+
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if format == logFormatText {
+		return slog.New(slog.NewTextHandler(w, options))
+	}
+
+	return slog.New(slog.NewJSONHandler(w, options))
+}
+
+func defaultLogFormat(appEnv string) string {
+	if appEnv == appEnvDevelopment {
+		return logFormatText
+	}
+
+	return logFormatJSON
+}
+
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", value)
+	}
+
+	return level, nil
+}
+
+func fatal(logger *slog.Logger, err error) {
+	logger.Error("fatal error", slog.Any("error", err))
+	os.Exit(1)
+}
+
+type loggerContextKey struct{}
+
+func withLogger(r *http.Request, logger *slog.Logger) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), loggerContextKey{}, logger))
+}
+
+func requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+type accessLogRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
+
+func (r *accessLogRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *accessLogRecorder) Write(p []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+func accessLogMiddleware(logger *slog.Logger, mux *http.ServeMux, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, pattern := mux.Handler(r)
+
+		reqLogger := logger.With(
+			slog.String("request_id", requestID(r)),
+			slog.String("method", r.Method),
+			slog.String("pattern", pattern),
+		)
+
+		recorder := &accessLogRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, withLogger(r, reqLogger))
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+
+		reqLogger.LogAttrs(r.Context(), slog.LevelInfo, "request completed",
+			slog.Int("status", recorder.statusCode),
+			slog.Int("bytes", recorder.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("country", requestCountry(r)),
+		)
+	}
+}
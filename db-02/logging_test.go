@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func Test_newLogger(t *testing.T) {
+	testCases := map[string]struct {
+		format    string
+		level     slog.Level
+		expPrefix string
+		expLines  int
+	}{
+		"json": {
+			format:    logFormatJSON,
+			level:     slog.LevelInfo,
+			expPrefix: `{"time":`,
+			expLines:  2,
+		},
+		"text": {
+			format:    logFormatText,
+			level:     slog.LevelInfo,
+			expPrefix: "time=",
+			expLines:  2,
+		},
+		"warn_level": {
+			format:    logFormatJSON,
+			level:     slog.LevelWarn,
+			expPrefix: `{"time":`,
+			expLines:  1,
+		},
+		"debug_level": {
+			format:    logFormatText,
+			level:     slog.LevelDebug,
+			expPrefix: "time=",
+			expLines:  3,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, tc.format, tc.level)
+
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Warn("warn")
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			assert.Len(t, lines, tc.expLines)
+			assert.True(t, strings.HasPrefix(lines[0], tc.expPrefix), lines[0])
+		})
+	}
+}
+
+func Test_parseLogLevel(t *testing.T) {
+	testCases := map[string]struct {
+		value    string
+		expLevel slog.Level
+		expErr   bool
+	}{
+		"debug": {
+			value:    "debug",
+			expLevel: slog.LevelDebug,
+		},
+		"upper_case": {
+			value:    "WARN",
+			expLevel: slog.LevelWarn,
+		},
+		"error": {
+			value:    "error",
+			expLevel: slog.LevelError,
+		},
+		"unknown": {
+			value:  "verbose",
+			expErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			level, err := parseLogLevel(tc.value)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expLevel, level)
+		})
+	}
+}
+
+func Test_defaultLogFormat(t *testing.T) {
+	assert.Equal(t, logFormatJSON, defaultLogFormat(appEnvProduction))
+	assert.Equal(t, logFormatText, defaultLogFormat(appEnvDevelopment))
+}
+
+func Test_accessLogMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("DELETE /cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		writeInternalError(w, r, errors.New("connection refused"))
+	})
+
+	testCases := map[string]struct {
+		method  string
+		path    string
+		expLogs []map[string]any
+	}{
+		"matched_route": {
+			method: http.MethodGet,
+			path:   "/cards/7",
+			expLogs: []map[string]any{
+				{"level": "INFO", "msg": "request completed", "request_id": "req-1", "method": "GET", "pattern": "GET /cards/{id}", "status": float64(201), "country": uaCountryCode},
+			},
+		},
+		"unmatched_route": {
+			method: http.MethodGet,
+			path:   "/unknown",
+			expLogs: []map[string]any{
+				{"level": "INFO", "msg": "request completed", "request_id": "req-1", "method": "GET", "pattern": "", "status": float64(404), "country": uaCountryCode},
+			},
+		},
+		"internal_error": {
+			method: http.MethodDelete,
+			path:   "/cards/7",
+			expLogs: []map[string]any{
+				{"level": "ERROR", "msg": "internal error", "request_id": "req-1", "method": "DELETE", "pattern": "DELETE /cards/{id}", "status": float64(500), "error": "connection refused"},
+				{"level": "INFO", "msg": "request completed", "request_id": "req-1", "method": "DELETE", "pattern": "DELETE /cards/{id}", "status": float64(500), "country": uaCountryCode},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, logFormatJSON, slog.LevelInfo)
+
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			request.Header.Set(xRequestIDHeaderKey, "req-1")
+			request.Header.Set(xCountryCodeHeaderKey, uaCountryCode)
+
+			rw := httptest.NewRecorder()
+			requestIDMiddleware(accessLogMiddleware(logger, mux, unmatchedRouteMiddleware(mux)))(rw, request)
+
+			var logs []map[string]any
+			decoder := json.NewDecoder(&buf)
+			for decoder.More() {
+				var entry map[string]any
+				require.NoError(t, decoder.Decode(&entry))
+				assert.Contains(t, entry, "time")
+				delete(entry, "time")
+				if entry["msg"] == "request completed" {
+					assert.Contains(t, entry, "latency")
+					assert.Equal(t, float64(rw.Body.Len()), entry["bytes"])
+					delete(entry, "latency")
+					delete(entry, "bytes")
+				}
+				logs = append(logs, entry)
+			}
+
+			assert.Equal(t, tc.expLogs, logs)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+type messageKey string
+
+const (
+	msgTitleMalformedRequest         messageKey = "problem.malformed-request"
+	msgTitleValidationFailed         messageKey = "problem.validation-failed"
+	msgTitleInvalidQuery             messageKey = "problem.invalid-query"
+	msgTitleUnauthorized             messageKey = "problem.unauthorized"
+	msgTitleForbidden                messageKey = "problem.forbidden"
+	msgTitleCountryNotAllowed        messageKey = "problem.country-not-allowed"
+	msgTitleNotFound                 messageKey = "problem.not-found"
+	msgTitleMethodNotAllowed         messageKey = "problem.method-not-allowed"
+	msgTitleCardNotFound             messageKey = "problem.card-not-found"
+	msgTitleAPIKeyNotFound           messageKey = "problem.api-key-not-found"
+	msgTitleCardNotDeleted           messageKey = "problem.card-not-deleted"
+	msgTitleDuplicateCard            messageKey = "problem.duplicate-card"
+	msgTitleIdempotencyKeyInProgress messageKey = "problem.idempotency-key-in-progress"
+	msgTitleVersionConflict          messageKey = "problem.version-conflict"
+	msgTitleUnsupportedMediaType     messageKey = "problem.unsupported-media-type"
+	msgTitleCardVerificationFailed   messageKey = "problem.card-verification-failed"
+	msgTitlePatchNotApplicable       messageKey = "problem.patch-not-applicable"
+	msgTitleIdempotencyKeyReused     messageKey = "problem.idempotency-key-reused"
+	msgTitlePreconditionRequired     messageKey = "problem.precondition-required"
+	msgTitleRateLimited              messageKey = "problem.rate-limited"
+	msgTitleInternalError            messageKey = "problem.internal-error"
+	msgTitleRequestCancelled         messageKey = "problem.request-cancelled"
+	msgTitleDeadlineExceeded         messageKey = "problem.deadline-exceeded"
+
+	msgBodyUnreadable             messageKey = "request.body-unreadable"
+	msgBodyNotCreditCard          messageKey = "request.body-not-credit-card"
+	msgBodyNotAPIKey              messageKey = "request.body-not-api-key"
+	msgCardIDNotInteger           messageKey = "request.card-id-not-integer"
+	msgIdempotencyKeyTooLong      messageKey = "request.idempotency-key-too-long"
+	msgCountryNotAllowed          messageKey = "request.country-not-allowed"
+	msgBearerTokenRequired        messageKey = "request.bearer-token-required"
+	msgBearerTokenInvalid         messageKey = "request.bearer-token-invalid"
+	msgAPIKeyInvalid              messageKey = "request.api-key-invalid"
+	msgScopeRequired              messageKey = "request.scope-required"
+	msgRateLimitExceeded          messageKey = "request.rate-limit-exceeded"
+	msgMethodNotAllowed           messageKey = "request.method-not-allowed"
+	msgRetryWithFreshCard         messageKey = "request.retry-with-fresh-card"
+	msgDuplicateCardNumber        messageKey = "request.duplicate-card-number"
+	msgIncludeDeletedAdminOnly    messageKey = "request.include-deleted-admin-only"
+	msgUnsupportedPatchType       messageKey = "patch.unsupported-type"
+	msgMalformedPatch             messageKey = "patch.malformed"
+	msgPatchNotApplicable         messageKey = "patch.not-applicable"
+	msgPatchNumberWriteOnly       messageKey = "patch.number-write-only"
+	msgQueryLast4Invalid          messageKey = "query.last4-invalid"
+	msgQueryExpirationDateInvalid messageKey = "query.expiration-date-invalid"
+	msgQueryTimestampInvalid      messageKey = "query.timestamp-invalid"
+	msgQueryIncludeDeletedInvalid messageKey = "query.include-deleted-invalid"
+	msgQueryLimitOutOfRange       messageKey = "query.limit-out-of-range"
+	msgQueryUnknownSortField      messageKey = "query.unknown-sort-field"
+	msgQueryDuplicateSortField    messageKey = "query.duplicate-sort-field"
+	msgQueryCursorInvalid         messageKey = "query.cursor-invalid"
+
+	msgValidationRequired              messageKey = "validation.required"
+	msgValidationLength                messageKey = "validation.length"
+	msgValidationMin                   messageKey = "validation.min"
+	msgValidationMax                   messageKey = "validation.max"
+	msgValidationCreditCard            messageKey = "validation.credit-card"
+	msgValidationExpirationDateInvalid messageKey = "validation.expiration-date-invalid"
+	msgValidationCardExpired           messageKey = "validation.card-expired"
+	msgValidationBrandNotAccepted      messageKey = "validation.brand-not-accepted"
+	msgValidationScopeUnknown          messageKey = "validation.scope-unknown"
+	msgValidationNotInFuture           messageKey = "validation.not-in-future"
+)
+
+var messageKeys = []messageKey{
+	msgTitleMalformedRequest,
+	msgTitleValidationFailed,
+	msgTitleInvalidQuery,
+	msgTitleUnauthorized,
+	msgTitleForbidden,
+	msgTitleCountryNotAllowed,
+	msgTitleNotFound,
+	msgTitleMethodNotAllowed,
+	msgTitleCardNotFound,
+	msgTitleAPIKeyNotFound,
+	msgTitleCardNotDeleted,
+	msgTitleDuplicateCard,
+	msgTitleIdempotencyKeyInProgress,
+	msgTitleVersionConflict,
+	msgTitleUnsupportedMediaType,
+	msgTitleCardVerificationFailed,
+	msgTitlePatchNotApplicable,
+	msgTitleIdempotencyKeyReused,
+	msgTitlePreconditionRequired,
+	msgTitleRateLimited,
+	msgTitleInternalError,
+	msgTitleRequestCancelled,
+	msgTitleDeadlineExceeded,
+
+	msgBodyUnreadable,
+	msgBodyNotCreditCard,
+	msgBodyNotAPIKey,
+	msgCardIDNotInteger,
+	msgIdempotencyKeyTooLong,
+	msgCountryNotAllowed,
+	msgBearerTokenRequired,
+	msgBearerTokenInvalid,
+	msgAPIKeyInvalid,
+	msgScopeRequired,
+	msgRateLimitExceeded,
+	msgMethodNotAllowed,
+	msgRetryWithFreshCard,
+	msgDuplicateCardNumber,
+	msgIncludeDeletedAdminOnly,
+	msgUnsupportedPatchType,
+	msgMalformedPatch,
+	msgPatchNotApplicable,
+	msgPatchNumberWriteOnly,
+	msgQueryLast4Invalid,
+	msgQueryExpirationDateInvalid,
+	msgQueryTimestampInvalid,
+	msgQueryIncludeDeletedInvalid,
+	msgQueryLimitOutOfRange,
+	msgQueryUnknownSortField,
+	msgQueryDuplicateSortField,
+	msgQueryCursorInvalid,
+
+	msgValidationRequired,
+	msgValidationLength,
+	msgValidationMin,
+	msgValidationMax,
+	msgValidationCreditCard,
+	msgValidationExpirationDateInvalid,
+	msgValidationCardExpired,
+	msgValidationBrandNotAccepted,
+	msgValidationScopeUnknown,
+	msgValidationNotInFuture,
+}
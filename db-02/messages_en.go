@@ -0,0 +1,66 @@
+package main
+
+var englishMessages = map[messageKey]string{
+	msgTitleMalformedRequest:         "Request is malformed",
+	msgTitleValidationFailed:         "Request validation failed",
+	msgTitleInvalidQuery:             "Query parameters are invalid",
+	msgTitleUnauthorized:             "Authentication is required",
+	msgTitleForbidden:                "Operation is not permitted",
+	msgTitleCountryNotAllowed:        "Country is not allowed",
+	msgTitleNotFound:                 "Resource not found",
+	msgTitleMethodNotAllowed:         "Method is not allowed",
+	msgTitleCardNotFound:             "Credit card not found",
+	msgTitleAPIKeyNotFound:           "API key not found",
+	msgTitleCardNotDeleted:           "Credit card is not deleted",
+	msgTitleDuplicateCard:            "Credit card already exists",
+	msgTitleIdempotencyKeyInProgress: "Request with this Idempotency-Key is still being processed",
+	msgTitleVersionConflict:          "Credit card was modified",
+	msgTitleUnsupportedMediaType:     "Content type is not supported",
+	msgTitleCardVerificationFailed:   "Card verification failed",
+	msgTitlePatchNotApplicable:       "Patch cannot be applied",
+	msgTitleIdempotencyKeyReused:     "Idempotency-Key was already used with a different request",
+	msgTitlePreconditionRequired:     "If-Match header is required",
+	msgTitleRateLimited:              "Too many requests",
+	msgTitleInternalError:            "Internal server error",
+	msgTitleRequestCancelled:         "Request cancelled",
+	msgTitleDeadlineExceeded:         "Request deadline exceeded",
+
+	msgBodyUnreadable:             "request body could not be read",
+	msgBodyNotCreditCard:          "request body must be a JSON credit card",
+	msgBodyNotAPIKey:              "request body must be a JSON API key request",
+	msgCardIDNotInteger:           "card id must be an integer",
+	msgIdempotencyKeyTooLong:      "Idempotency-Key must be at most %d characters",
+	msgCountryNotAllowed:          "country %q is not allowed",
+	msgBearerTokenRequired:        "send a bearer token in the Authorization header",
+	msgBearerTokenInvalid:         "bearer token is invalid or expired",
+	msgAPIKeyInvalid:              "API key is invalid, expired or revoked",
+	msgScopeRequired:              "the %s scope is required",
+	msgRateLimitExceeded:          "rate limit of %d requests exceeded, retry in %d seconds",
+	msgMethodNotAllowed:           "method %s is not allowed, use one of: %s",
+	msgRetryWithFreshCard:         "fetch the credit card again and retry",
+	msgDuplicateCardNumber:        "credit card %d has the same number",
+	msgIncludeDeletedAdminOnly:    "include_deleted is only available to administrators",
+	msgUnsupportedPatchType:       "unsupported patch content type",
+	msgMalformedPatch:             "malformed patch document: %v",
+	msgPatchNotApplicable:         "patch cannot be applied to the credit card: %v",
+	msgPatchNumberWriteOnly:       "number is write-only and cannot be used in a %s operation",
+	msgQueryLast4Invalid:          "last4 must be exactly 4 digits",
+	msgQueryExpirationDateInvalid: "%s must be a month and year such as MM/YY, MM/YYYY or YYYY-MM",
+	msgQueryTimestampInvalid:      "%s must be an RFC 3339 timestamp",
+	msgQueryIncludeDeletedInvalid: "include_deleted must be true or false",
+	msgQueryLimitOutOfRange:       "limit must be between 1 and %d",
+	msgQueryUnknownSortField:      "unknown sort field %q",
+	msgQueryDuplicateSortField:    "duplicate sort field %q",
+	msgQueryCursorInvalid:         "invalid cursor",
+
+	msgValidationRequired:              "cannot be blank",
+	msgValidationLength:                "the length must be between %d and %d",
+	msgValidationMin:                   "must be no less than %d",
+	msgValidationMax:                   "must be no greater than %d",
+	msgValidationCreditCard:            "must be a valid credit card number",
+	msgValidationExpirationDateInvalid: "must be a valid expiration date",
+	msgValidationCardExpired:           "card has expired",
+	msgValidationBrandNotAccepted:      "card brand %q is not accepted",
+	msgValidationScopeUnknown:          "unknown scope %q",
+	msgValidationNotInFuture:           "must be in the future",
+}
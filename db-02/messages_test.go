@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formatVerbRegexp = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func Test_messageCatalogsComplete(t *testing.T) {
+	for lang, catalog := range messageCatalogs {
+		t.Run(lang, func(t *testing.T) {
+			for _, key := range messageKeys {
+				message, ok := catalog[key]
+				if !assert.True(t, ok, "missing message %q", key) {
+					continue
+				}
+
+				assert.NotEmpty(t, message, "empty message %q", key)
+				assert.Equal(t, formatVerbRegexp.FindAllString(englishMessages[key], -1), formatVerbRegexp.FindAllString(message, -1), "format verbs of %q", key)
+			}
+
+			assert.Len(t, catalog, len(messageKeys))
+		})
+	}
+}
+
+func Test_problemKindTitlesTranslated(t *testing.T) {
+	kinds := []problemKind{
+		problemMalformedRequest,
+		problemValidationFailed,
+		problemInvalidQuery,
+		problemUnauthorized,
+		problemForbidden,
+		problemCountryNotAllowed,
+		problemNotFound,
+		problemMethodNotAllowed,
+		problemCardNotFound,
+		problemAPIKeyNotFound,
+		problemCardNotDeleted,
+		problemDuplicateCard,
+		problemIdempotencyKeyInProgress,
+		problemVersionConflict,
+		problemUnsupportedMediaType,
+		problemCardVerificationFailed,
+		problemPatchNotApplicable,
+		problemIdempotencyKeyReused,
+		problemPreconditionRequired,
+		problemRateLimited,
+		problemInternalError,
+		problemRequestCancelled,
+		problemDeadlineExceeded,
+	}
+
+	for _, kind := range kinds {
+		assert.Contains(t, messageKeys, kind.Title, kind.Type)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+var ukrainianMessages = map[messageKey]string{
+	msgTitleMalformedRequest:         "Некоректний запит",
+	msgTitleValidationFailed:         "Запит не пройшов перевірку",
+	msgTitleInvalidQuery:             "Некоректні параметри запиту",
+	msgTitleUnauthorized:             "Потрібна автентифікація",
+	msgTitleForbidden:                "Операцію заборонено",
+	msgTitleCountryNotAllowed:        "Країна не дозволена",
+	msgTitleNotFound:                 "Ресурс не знайдено",
+	msgTitleMethodNotAllowed:         "Метод не дозволено",
+	msgTitleCardNotFound:             "Картку не знайдено",
+	msgTitleAPIKeyNotFound:           "API-ключ не знайдено",
+	msgTitleCardNotDeleted:           "Картку не видалено",
+	msgTitleDuplicateCard:            "Картка вже існує",
+	msgTitleIdempotencyKeyInProgress: "Запит із цим Idempotency-Key ще виконується",
+	msgTitleVersionConflict:          "Картку вже змінили",
+	msgTitleUnsupportedMediaType:     "Тип вмісту не підтримується",
+	msgTitleCardVerificationFailed:   "Картку не підтверджено",
+	msgTitlePatchNotApplicable:       "Зміну неможливо застосувати",
+	msgTitleIdempotencyKeyReused:     "Idempotency-Key уже використано для іншого запиту",
+	msgTitlePreconditionRequired:     "Потрібен заголовок If-Match",
+	msgTitleRateLimited:              "Забагато запитів",
+	msgTitleInternalError:            "Внутрішня помилка сервера",
+	msgTitleRequestCancelled:         "Запит скасовано",
+	msgTitleDeadlineExceeded:         "Час на виконання запиту вичерпано",
+
+	msgBodyUnreadable:             "не вдалося прочитати тіло запиту",
+	msgBodyNotCreditCard:          "тіло запиту має бути карткою у форматі JSON",
+	msgBodyNotAPIKey:              "тіло запиту має бути описом API-ключа у форматі JSON",
+	msgCardIDNotInteger:           "ідентифікатор картки має бути цілим числом",
+	msgIdempotencyKeyTooLong:      "Idempotency-Key має містити не більше %d символів",
+	msgCountryNotAllowed:          "країна %q не дозволена",
+	msgBearerTokenRequired:        "передайте bearer-токен у заголовку Authorization",
+	msgBearerTokenInvalid:         "bearer-токен недійсний або прострочений",
+	msgAPIKeyInvalid:              "API-ключ недійсний, прострочений або відкликаний",
+	msgScopeRequired:              "потрібен дозвіл %s",
+	msgRateLimitExceeded:          "перевищено ліміт у %d запитів, повторіть через %d с",
+	msgMethodNotAllowed:           "метод %s не дозволено, використайте один із: %s",
+	msgRetryWithFreshCard:         "отримайте картку ще раз і повторіть запит",
+	msgDuplicateCardNumber:        "картка %d має той самий номер",
+	msgIncludeDeletedAdminOnly:    "include_deleted доступний лише адміністраторам",
+	msgUnsupportedPatchType:       "тип документа зміни не підтримується",
+	msgMalformedPatch:             "некоректний документ зміни: %v",
+	msgPatchNotApplicable:         "зміну неможливо застосувати до картки: %v",
+	msgPatchNumberWriteOnly:       "номер картки доступний лише для запису і не може використовуватися в операції %s",
+	msgQueryLast4Invalid:          "last4 має складатися рівно з 4 цифр",
+	msgQueryExpirationDateInvalid: "%s має бути місяцем і роком, наприклад MM/YY, MM/YYYY або YYYY-MM",
+	msgQueryTimestampInvalid:      "%s має бути часовою міткою RFC 3339",
+	msgQueryIncludeDeletedInvalid: "include_deleted має бути true або false",
+	msgQueryLimitOutOfRange:       "limit має бути від 1 до %d",
+	msgQueryUnknownSortField:      "невідоме поле сортування %q",
+	msgQueryDuplicateSortField:    "поле сортування %q повторюється",
+	msgQueryCursorInvalid:         "некоректний курсор",
+
+	msgValidationRequired:              "не може бути порожнім",
+	msgValidationLength:                "довжина має бути від %d до %d символів",
+	msgValidationMin:                   "має бути не менше %d",
+	msgValidationMax:                   "має бути не більше %d",
+	msgValidationCreditCard:            "має бути дійсним номером картки",
+	msgValidationExpirationDateInvalid: "дата не коректна",
+	msgValidationCardExpired:           "термін дії картки минув",
+	msgValidationBrandNotAccepted:      "платіжна система %q не приймається",
+	msgValidationScopeUnknown:          "невідомий дозвіл %q",
+	msgValidationNotInFuture:           "має бути в майбутньому",
+}
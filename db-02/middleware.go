@@ -1,11 +1,18 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
 
-func isCountryAllowedMiddleware(next http.HandlerFunc) http.HandlerFunc {
+func isCountryAllowedMiddleware(countries *countryPolicyStore, pattern string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if isCountryAllowed(r.Header) == false {
-			w.WriteHeader(http.StatusForbidden)
+		country := requestCountry(r)
+		if !countries.allows(pattern, country) {
+			writeProblem(w, r, problemCountryNotAllowed, localize(r, msgCountryNotAllowed, country))
 			return
 		}
 
@@ -13,17 +20,98 @@ func isCountryAllowedMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func timeoutMiddleware(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if timeout <= 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
 const xCountryCodeHeaderKey = "X-Country-Code"
-const uaCountryCode = "UA"
-const usCountryCode = "US"
-const ukCountryCode = "UK"
 
-func isCountryAllowed(header http.Header) bool {
-	code := header.Get(xCountryCodeHeaderKey)
+const xRequestIDHeaderKey = "X-Request-ID"
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(xRequestIDHeaderKey)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
 
-	switch code {
-	case uaCountryCode, usCountryCode, ukCountryCode:
-		return true
+		w.Header().Set(xRequestIDHeaderKey, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id)))
 	}
-	return false
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey{}).(string)
+	return id
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func unmatchedRouteMiddleware(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fallback, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		probe := &headerRecorder{header: make(http.Header)}
+		fallback.ServeHTTP(probe, r)
+
+		if probe.statusCode == http.StatusMethodNotAllowed {
+			allow := probe.header.Get("Allow")
+			w.Header().Set("Allow", allow)
+			writeProblem(w, r, problemMethodNotAllowed, localize(r, msgMethodNotAllowed, r.Method, allow))
+			return
+		}
+
+		writeProblem(w, r, problemNotFound, "")
+	}
+}
+
+type headerRecorder struct {
+	header     http.Header
+	statusCode int
+}
+
+func (h *headerRecorder) Header() http.Header {
+	return h.header
+}
+
+func (h *headerRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (h *headerRecorder) WriteHeader(statusCode int) {
+	h.statusCode = statusCode
 }
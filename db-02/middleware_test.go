@@ -1,42 +1,215 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	uaCountryCode = "UA"
+	usCountryCode = "US"
+	gbCountryCode = "GB"
 )
 
 func Test_isCountryAllowedMiddleware(t *testing.T) {
+	countries, err := loadCountryPolicy("")
+	require.NoError(t, err)
+
 	testCases := map[string]struct {
 		countryCode   string
-		nextHandler   http.HandlerFunc
 		expStatusCode int
+		expBody       string
 	}{
 		"not_allowed_country_code": {
 			countryCode:   "FR",
 			expStatusCode: http.StatusForbidden,
+			expBody:       testProblem(problemCountryNotAllowed, `country "FR" is not allowed`, nil),
+		},
+		"non_iso_country_code": {
+			countryCode:   "UK",
+			expStatusCode: http.StatusForbidden,
+			expBody:       testProblem(problemCountryNotAllowed, `country "UK" is not allowed`, nil),
+		},
+		"missing_country_code": {
+			expStatusCode: http.StatusForbidden,
+			expBody:       testProblem(problemCountryNotAllowed, `country "" is not allowed`, nil),
 		},
 		"allowed_country_code": {
-			countryCode: usCountryCode,
-			nextHandler: func(writer http.ResponseWriter, request *http.Request) {
-				writer.WriteHeader(http.StatusAccepted)
-			},
+			countryCode:   gbCountryCode,
 			expStatusCode: http.StatusAccepted,
 		},
 	}
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			request := http.Request{
-				Method: http.MethodGet,
-				Header: http.Header{xCountryCodeHeaderKey: []string{tc.countryCode}},
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			request.Header.Set(xCountryCodeHeaderKey, tc.countryCode)
+
+			rw := httptest.NewRecorder()
+			isCountryAllowedMiddleware(countries, "GET /cards", func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusAccepted)
+			})(rw, request)
+
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expBody, rw.Body.String())
+		})
+	}
+}
+
+func Test_timeoutMiddleware(t *testing.T) {
+	testCases := map[string]struct {
+		timeout     time.Duration
+		expDeadline bool
+	}{
+		"no_timeout": {
+			timeout:     0,
+			expDeadline: false,
+		},
+		"with_timeout": {
+			timeout:     time.Second,
+			expDeadline: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var gotDeadline bool
+			next := func(w http.ResponseWriter, r *http.Request) {
+				_, gotDeadline = r.Context().Deadline()
 			}
 
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+
 			rw := httptest.NewRecorder()
-			isCountryAllowedMiddleware(tc.nextHandler)(rw, &request)
+			timeoutMiddleware(tc.timeout, next)(rw, request)
+			assert.Equal(t, tc.expDeadline, gotDeadline)
+		})
+	}
+}
+
+func Test_timeoutMiddleware_cancelsAfterResponse(t *testing.T) {
+	var ctx context.Context
+	next := func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}
+
+	rw := httptest.NewRecorder()
+	timeoutMiddleware(time.Minute, next)(rw, httptest.NewRequest(http.MethodGet, "/cards", nil))
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func Test_requestIDMiddleware(t *testing.T) {
+	testCases := map[string]struct {
+		requestID string
+		expKept   bool
+	}{
+		"generated_when_missing": {
+			requestID: "",
+			expKept:   false,
+		},
+		"kept_when_valid": {
+			requestID: "req-1",
+			expKept:   true,
+		},
+		"replaced_when_not_printable": {
+			requestID: "req 1",
+			expKept:   false,
+		},
+		"replaced_when_too_long": {
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+			expKept:   false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var gotID string
+			next := func(w http.ResponseWriter, r *http.Request) {
+				gotID = requestID(r)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			if tc.requestID != "" {
+				request.Header.Set(xRequestIDHeaderKey, tc.requestID)
+			}
+
+			rw := httptest.NewRecorder()
+			requestIDMiddleware(next)(rw, request)
+
+			assert.Equal(t, gotID, rw.Header().Get(xRequestIDHeaderKey))
+			if tc.expKept {
+				assert.Equal(t, tc.requestID, gotID)
+			} else {
+				assert.Len(t, gotID, 32)
+			}
+		})
+	}
+}
+
+func Test_unmatchedRouteMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("DELETE /cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testCases := map[string]struct {
+		method         string
+		path           string
+		acceptLanguage string
+
+		expStatusCode int
+		expAllow      string
+		expBody       string
+	}{
+		"matched": {
+			method:        http.MethodDelete,
+			path:          "/cards/1",
+			expStatusCode: http.StatusNoContent,
+		},
+		"method_not_allowed": {
+			method:        http.MethodPost,
+			path:          "/cards/1",
+			expStatusCode: http.StatusMethodNotAllowed,
+			expAllow:      "DELETE, GET, HEAD",
+			expBody:       testProblem(problemMethodNotAllowed, "method POST is not allowed, use one of: DELETE, GET, HEAD", nil),
+		},
+		"method_not_allowed_ukrainian": {
+			method:         http.MethodPost,
+			path:           "/cards/1",
+			acceptLanguage: languageUkrainian,
+			expStatusCode:  http.StatusMethodNotAllowed,
+			expAllow:       "DELETE, GET, HEAD",
+			expBody:        `{"type":"/problems/method-not-allowed","title":"Метод не дозволено","status":405,"detail":"метод POST не дозволено, використайте один із: DELETE, GET, HEAD"}`,
+		},
+		"not_found": {
+			method:        http.MethodGet,
+			path:          "/wallets",
+			expStatusCode: http.StatusNotFound,
+			expBody:       testProblem(problemNotFound, "", nil),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			request.Header.Set(acceptLanguageHeaderKey, tc.acceptLanguage)
+
+			rw := httptest.NewRecorder()
+			languageMiddleware(languageEnglish, unmatchedRouteMiddleware(mux))(rw, request)
+
 			assert.Equal(t, tc.expStatusCode, rw.Code)
+			assert.Equal(t, tc.expAllow, rw.Header().Get("Allow"))
+			assert.Equal(t, tc.expBody, rw.Body.String())
 		})
 	}
 }
@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN brand      VARCHAR(32) NOT NULL DEFAULT 'unknown',
+    ADD COLUMN last4      VARCHAR(4)  NOT NULL DEFAULT '';
+
+UPDATE credit_cards
+SET last4 = RIGHT(number, 4),
+    brand = CASE
+                WHEN number LIKE '9804%' THEN 'prostir'
+                WHEN LEFT(number, 4) BETWEEN '2221' AND '2720' THEN 'mastercard'
+                WHEN LEFT(number, 2) BETWEEN '51' AND '55' THEN 'mastercard'
+                WHEN number LIKE '34%' OR number LIKE '37%' THEN 'amex'
+                WHEN number LIKE '4%' THEN 'visa'
+                ELSE 'unknown'
+        END;
+
+CREATE INDEX credit_cards_brand_idx ON credit_cards (brand);
+CREATE INDEX credit_cards_last4_idx ON credit_cards (last4);
+CREATE INDEX credit_cards_created_at_idx ON credit_cards (created_at);
+
+-- +goose Down
+DROP INDEX credit_cards_created_at_idx;
+DROP INDEX credit_cards_last4_idx;
+DROP INDEX credit_cards_brand_idx;
+
+ALTER TABLE credit_cards
+    DROP COLUMN last4,
+    DROP COLUMN brand,
+    DROP COLUMN created_at;
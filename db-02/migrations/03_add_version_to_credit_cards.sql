@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE credit_cards
+    DROP COLUMN version;
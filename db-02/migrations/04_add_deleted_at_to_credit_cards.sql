@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX credit_cards_deleted_at_idx ON credit_cards (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX credit_cards_deleted_at_idx;
+
+ALTER TABLE credit_cards
+    DROP COLUMN deleted_at;
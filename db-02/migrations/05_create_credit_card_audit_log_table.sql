@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE credit_card_audit_log
+(
+    id           SERIAL       NOT NULL,
+    card_id      INT          NOT NULL,
+    action       VARCHAR(16)  NOT NULL,
+    before       JSONB        NULL,
+    after        JSONB        NULL,
+    actor        VARCHAR(255) NOT NULL,
+    country_code VARCHAR(2)   NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX credit_card_audit_log_card_id_idx ON credit_card_audit_log (card_id, id);
+
+-- +goose Down
+DROP TABLE credit_card_audit_log;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN number_ciphertext BYTEA       NULL,
+    ADD COLUMN number_data_key   BYTEA       NULL,
+    ADD COLUMN number_key_id     VARCHAR(64) NULL,
+    ALTER COLUMN number DROP NOT NULL;
+
+CREATE INDEX credit_cards_number_key_id_idx ON credit_cards (number_key_id);
+
+-- +goose Down
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM credit_cards WHERE number IS NULL) THEN
+        RAISE EXCEPTION 'credit_cards has numbers stored only encrypted, dropping the ciphertext columns would lose them';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+DROP INDEX credit_cards_number_key_id_idx;
+
+ALTER TABLE credit_cards
+    ALTER COLUMN number SET NOT NULL,
+    DROP COLUMN number_key_id,
+    DROP COLUMN number_data_key,
+    DROP COLUMN number_ciphertext;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    DROP COLUMN cvv;
+
+-- +goose Down
+ALTER TABLE credit_cards
+    ADD COLUMN cvv INT NULL;
@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN expires_on DATE NULL;
+
+UPDATE credit_cards
+SET expires_on = CASE
+                     WHEN expiration_date ~ '^(0[1-9]|1[0-2])/[0-9]{2}$'
+                         THEN MAKE_DATE(2000 + SUBSTR(expiration_date, 4, 2)::INT, SUBSTR(expiration_date, 1, 2)::INT, 1)
+                     WHEN expiration_date ~ '^(0[1-9]|1[0-2])/[0-9]{4}$'
+                         THEN MAKE_DATE(SUBSTR(expiration_date, 4, 4)::INT, SUBSTR(expiration_date, 1, 2)::INT, 1)
+                     WHEN expiration_date ~ '^[0-9]{4}-(0[1-9]|1[0-2])$'
+                         THEN MAKE_DATE(SUBSTR(expiration_date, 1, 4)::INT, SUBSTR(expiration_date, 6, 2)::INT, 1)
+    END
+WHERE expiration_date IS NOT NULL;
+
+UPDATE credit_cards
+SET expires_on = MAKE_DATE(SUBSTRING(TRIM(expiration_date) FROM '([0-9]{4})')::INT, months.month, 1)
+FROM (VALUES ('січ', 1), ('лют', 2), ('берез', 3), ('квіт', 4), ('трав', 5), ('черв', 6),
+             ('лип', 7), ('серп', 8), ('верес', 9), ('жовт', 10), ('листопад', 11), ('груд', 12)) AS months(prefix, month)
+WHERE expires_on IS NULL
+  AND LOWER(TRIM(expiration_date)) ~ ('^([0-9]{1,2}\s+)?' || months.prefix || '\S*\s+[0-9]{4}\s*(р\.?|року)?$');
+
+ALTER TABLE credit_cards
+    ALTER COLUMN expires_on SET NOT NULL,
+    DROP COLUMN expiration_date;
+
+ALTER TABLE credit_cards
+    RENAME COLUMN expires_on TO expiration_date;
+
+CREATE INDEX credit_cards_expiration_date_idx ON credit_cards (expiration_date);
+
+-- +goose Down
+DROP INDEX credit_cards_expiration_date_idx;
+
+ALTER TABLE credit_cards
+    ALTER COLUMN expiration_date TYPE VARCHAR(255) USING TO_CHAR(expiration_date, 'MM/YY');
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN number_fingerprint BYTEA NULL,
+    ADD COLUMN duplicate_of       INT   NULL REFERENCES credit_cards (id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX credit_cards_number_fingerprint_key ON credit_cards (number_fingerprint)
+    WHERE deleted_at IS NULL AND duplicate_of IS NULL;
+
+CREATE INDEX credit_cards_number_fingerprint_idx ON credit_cards (number_fingerprint);
+
+-- +goose Down
+DROP INDEX credit_cards_number_fingerprint_idx;
+DROP INDEX credit_cards_number_fingerprint_key;
+
+ALTER TABLE credit_cards
+    DROP COLUMN duplicate_of,
+    DROP COLUMN number_fingerprint;
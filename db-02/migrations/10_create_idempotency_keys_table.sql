@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE idempotency_keys
+(
+    key             VARCHAR(255) NOT NULL,
+    request_hash    VARCHAR(64)  NOT NULL,
+    status_code     INT          NULL,
+    response_header JSONB        NULL,
+    response_body   BYTEA        NULL,
+    created_at      TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE credit_cards
+    ADD COLUMN owner_id VARCHAR(255) NOT NULL DEFAULT '';
+
+DROP INDEX credit_cards_number_fingerprint_key;
+
+CREATE UNIQUE INDEX credit_cards_number_fingerprint_key ON credit_cards (owner_id, number_fingerprint)
+    WHERE deleted_at IS NULL AND duplicate_of IS NULL;
+
+CREATE INDEX credit_cards_owner_id_idx ON credit_cards (owner_id, id);
+
+ALTER TABLE credit_card_audit_log
+    ADD COLUMN owner_id VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE idempotency_keys
+    ALTER COLUMN key TYPE TEXT;
+
+-- +goose Down
+ALTER TABLE idempotency_keys
+    ALTER COLUMN key TYPE VARCHAR(255);
+
+ALTER TABLE credit_card_audit_log
+    DROP COLUMN owner_id;
+
+DROP INDEX credit_cards_owner_id_idx;
+DROP INDEX credit_cards_number_fingerprint_key;
+
+CREATE UNIQUE INDEX credit_cards_number_fingerprint_key ON credit_cards (number_fingerprint)
+    WHERE deleted_at IS NULL AND duplicate_of IS NULL;
+
+ALTER TABLE credit_cards
+    DROP COLUMN owner_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE api_keys
+(
+    id           SERIAL       NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    owner_id     VARCHAR(255) NOT NULL,
+    scopes       TEXT         NOT NULL,
+    key_hash     BYTEA        NOT NULL,
+    expires_at   TIMESTAMPTZ  NULL,
+    last_used_at TIMESTAMPTZ  NULL,
+    created_at   TIMESTAMPTZ  NOT NULL,
+    revoked_at   TIMESTAMPTZ  NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX api_keys_key_hash_key ON api_keys (key_hash);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE rate_limit_buckets
+(
+    key        TEXT             NOT NULL,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ      NOT NULL,
+    full_at    TIMESTAMPTZ      NOT NULL,
+    PRIMARY KEY (key)
+);
+
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
@@ -1,9 +1,74 @@
 package main
 
+import "time"
+
 type creditCard struct {
-	ID             int    `json:"id"`
+	ID             int            `json:"id"`
+	OwnerID        string         `json:"-"`
+	Number         string         `json:"number"`
+	ExpirationDate expirationDate `json:"expiration_date"`
+	Holder         string         `json:"holder"`
+	Brand          string         `json:"-"`
+	Fingerprint    []byte         `json:"-"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Version        int            `json:"-"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"`
+}
+
+type cardRequest struct {
 	Number         string `json:"number"`
 	ExpirationDate string `json:"expiration_date"`
-	CvvCode        int    `json:"cvv"`
 	Holder         string `json:"holder"`
+	CvvCode        int    `json:"cvv"`
+	Brand          string `json:"-"`
+	Fingerprint    []byte `json:"-"`
+}
+
+func (r cardRequest) card() creditCard {
+	expiration, _ := parseExpirationDate(r.ExpirationDate)
+	return creditCard{
+		Number:         r.Number,
+		ExpirationDate: expiration,
+		Holder:         r.Holder,
+		Brand:          r.Brand,
+		Fingerprint:    r.Fingerprint,
+	}
+}
+
+type cardResponse struct {
+	ID             int            `json:"id"`
+	Number         string         `json:"number"`
+	Last4          string         `json:"last4"`
+	Brand          string         `json:"brand"`
+	ExpirationDate expirationDate `json:"expiration_date"`
+	Holder         string         `json:"holder"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      *time.Time     `json:"deleted_at,omitempty"`
+}
+
+func newCardResponse(card creditCard) cardResponse {
+	return cardResponse{
+		ID:             card.ID,
+		Number:         maskPAN(card.Number),
+		Last4:          cardLast4(card.Number),
+		Brand:          card.Brand,
+		ExpirationDate: card.ExpirationDate,
+		Holder:         card.Holder,
+		CreatedAt:      card.CreatedAt,
+		DeletedAt:      card.DeletedAt,
+	}
+}
+
+func newCardResponses(cards []creditCard) []cardResponse {
+	resp := make([]cardResponse, 0, len(cards))
+	for _, card := range cards {
+		resp = append(resp, newCardResponse(card))
+	}
+
+	return resp
+}
+
+type revealedCard struct {
+	ID     int    `json:"id"`
+	Number string `json:"number"`
 }
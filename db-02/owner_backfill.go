@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func (s *postgresCardStore) CountUnownedCards(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM credit_cards WHERE owner_id=''").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count unowned credit cards: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s *postgresCardStore) AssignLegacyOwner(ctx context.Context, owner string) (int, int, error) {
+	if owner == "" {
+		return 0, 0, fmt.Errorf("legacy owner must not be empty")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin legacy owner assignment: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE credit_card_audit_log SET owner_id=$1 WHERE owner_id='' AND card_id IN (SELECT id FROM credit_cards WHERE owner_id='')", owner)
+	if err != nil {
+		return 0, 0, fmt.Errorf("exec assign owner to audit log: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE credit_cards SET duplicate_of=(SELECT existing.id FROM credit_cards existing WHERE existing.owner_id=$1 AND existing.number_fingerprint=credit_cards.number_fingerprint AND existing.deleted_at IS NULL AND existing.duplicate_of IS NULL) "+
+		"WHERE owner_id='' AND deleted_at IS NULL AND duplicate_of IS NULL AND EXISTS (SELECT 1 FROM credit_cards existing WHERE existing.owner_id=$1 AND existing.number_fingerprint=credit_cards.number_fingerprint AND existing.deleted_at IS NULL AND existing.duplicate_of IS NULL)", owner)
+	if err != nil {
+		return 0, 0, fmt.Errorf("exec mark legacy duplicates: %w", err)
+	}
+
+	duplicates, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("rows affected on mark legacy duplicates: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, "UPDATE credit_cards SET owner_id=$1 WHERE owner_id=''", owner)
+	if err != nil {
+		return 0, 0, fmt.Errorf("exec assign owner to credit cards: %w", err)
+	}
+
+	assigned, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("rows affected on assign owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit legacy owner assignment: %w", err)
+	}
+
+	return int(assigned), int(duplicates), nil
+}
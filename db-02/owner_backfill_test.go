@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresCardStore_AssignLegacyOwner(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	audit := newPostgresAuditLog(db)
+	store := &postgresCardStore{db: db, audit: audit, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+	fingerprints := testFingerprinter(t)
+
+	seedCards(t, store, []creditCard{
+		{OwnerID: "alice", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко", Brand: brandVisa, Fingerprint: fingerprints.fingerprint("4263982640269299")},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("01/30"), Holder: "Іванко", Brand: brandVisa, Fingerprint: fingerprints.fingerprint("4263982640269299")},
+		{Number: "5375414100000000", ExpirationDate: testExpirationDate("02/31"), Holder: "Петро", Brand: brandMastercard, Fingerprint: fingerprints.fingerprint("5375414100000000")},
+		{OwnerID: "bob", Number: "5375414100000000", ExpirationDate: testExpirationDate("03/32"), Holder: "Богдан", Brand: brandMastercard, Fingerprint: fingerprints.fingerprint("5375414100000000")},
+	})
+	unowned, err := store.CountUnownedCards(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, unowned)
+	assert.Error(t, checkUnownedCards(store))
+
+	assigned, duplicates, err := store.AssignLegacyOwner(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, assigned)
+	assert.Equal(t, 1, duplicates)
+
+	unowned, err = store.CountUnownedCards(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, unowned)
+	assert.NoError(t, checkUnownedCards(store))
+
+	page, err := store.ListCards(ctx, listCardsQuery{OwnerID: "alice"})
+	require.NoError(t, err)
+	var ids []int
+	for _, card := range page.Cards {
+		ids = append(ids, card.ID)
+	}
+	assert.Equal(t, []int{1, 2, 3}, ids)
+
+	entries, err := audit.ListAudit(ctx, "alice", 2)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	groups, err := store.ListDuplicateCards(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []duplicateCardGroup{{OwnerID: "alice", Last4: "9299", Brand: brandVisa, CardIDs: []int{1, 2}}}, groups)
+
+	_, _, err = store.AssignLegacyOwner(ctx, "")
+	assert.Error(t, err)
+}
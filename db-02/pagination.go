@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type encodedCursor struct {
+	ID     int      `json:"id"`
+	Values []string `json:"values,omitempty"`
+}
+
+func cardCursorFor(card creditCard, sort []sortField) cardCursor {
+	return cardCursor{ID: card.ID, Values: cardSortKeys(card, sort)}
+}
+
+func encodeCursor(cursor cardCursor) string {
+	encoded := encodedCursor{ID: cursor.ID}
+	for _, value := range cursor.Values {
+		switch value := value.(type) {
+		case time.Time:
+			encoded.Values = append(encoded.Values, value.UTC().Format(time.RFC3339Nano))
+		default:
+			encoded.Values = append(encoded.Values, fmt.Sprint(value))
+		}
+	}
+
+	raw, _ := json.Marshal(encoded)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string, sort []sortField) (cardCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cardCursor{}, errInvalidCursor
+	}
+
+	var encoded encodedCursor
+	if err := json.Unmarshal(raw, &encoded); err != nil || encoded.ID < 1 || len(encoded.Values) != len(sort) {
+		return cardCursor{}, errInvalidCursor
+	}
+
+	cursor := cardCursor{ID: encoded.ID}
+	for i, field := range sort {
+		key, err := cardSortFields[field.Name].parseKey(encoded.Values[i])
+		if err != nil {
+			return cardCursor{}, errInvalidCursor
+		}
+		cursor.Values = append(cursor.Values, key)
+	}
+
+	return cursor, nil
+}
+
+func nextPageLink(requestURL *url.URL, query listCardsQuery, page cardsPage) string {
+	last := page.Cards[len(page.Cards)-1]
+
+	params := requestURL.Query()
+	params.Set("limit", strconv.Itoa(query.Limit))
+	params.Set("after", encodeCursor(cardCursorFor(last, query.Sort)))
+
+	next := url.URL{Path: requestURL.Path, RawQuery: params.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"next\"", next.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeCursor(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 8, 9, 30, 0, 123456000, time.UTC)
+	sortByHolderAndCreatedAt := []sortField{{Name: "holder"}, {Name: "created_at", Desc: true}}
+
+	testCases := map[string]struct {
+		value     string
+		sort      []sortField
+		expCursor cardCursor
+		expErr    error
+	}{
+		"round_trip": {
+			value:     encodeCursor(cardCursor{ID: 42}),
+			expCursor: cardCursor{ID: 42, Values: nil},
+		},
+		"round_trip_with_sort_values": {
+			value:     encodeCursor(cardCursor{ID: 42, Values: []any{"Іванко", createdAt}}),
+			sort:      sortByHolderAndCreatedAt,
+			expCursor: cardCursor{ID: 42, Values: []any{"Іванко", createdAt}},
+		},
+		"sort_changed_between_pages": {
+			value:  encodeCursor(cardCursor{ID: 42}),
+			sort:   sortByHolderAndCreatedAt,
+			expErr: errInvalidCursor,
+		},
+		"invalid_sort_value": {
+			value:  encodeCursor(cardCursor{ID: 42, Values: []any{"Іванко", "вчора"}}),
+			sort:   sortByHolderAndCreatedAt,
+			expErr: errInvalidCursor,
+		},
+		"not_base64": {
+			value:  "!!!",
+			expErr: errInvalidCursor,
+		},
+		"not_json": {
+			value:  "bm90LWpzb24",
+			expErr: errInvalidCursor,
+		},
+		"non_positive_id": {
+			value:  encodeCursor(cardCursor{ID: 0}),
+			expErr: errInvalidCursor,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cursor, err := decodeCursor(tc.value, tc.sort)
+			assert.ErrorIs(t, err, tc.expErr)
+			assert.Equal(t, tc.expCursor, cursor)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "strings"
+
+func cardLast4(number string) string {
+	if len(number) < 4 {
+		return number
+	}
+
+	return number[len(number)-4:]
+}
+
+func maskPAN(number string) string {
+	if len(number) < 10 {
+		return strings.Repeat("*", len(number))
+	}
+
+	return number[:6] + strings.Repeat("*", len(number)-10) + number[len(number)-4:]
+}
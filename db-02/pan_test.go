@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_maskPAN(t *testing.T) {
+	testCases := map[string]struct {
+		number    string
+		expMasked string
+	}{
+		"visa_16":   {number: "4263982640269299", expMasked: "426398******9299"},
+		"amex_15":   {number: "378282246310005", expMasked: "378282*****0005"},
+		"pan_19":    {number: "6250941006528599123", expMasked: "625094*********9123"},
+		"too_short": {number: "426398929", expMasked: "*********"},
+		"empty":     {number: "", expMasked: ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expMasked, maskPAN(tc.number))
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+const jsonPatchContentType = "application/json-patch+json"
+const numberPatchPath = "/number"
+
+var (
+	errUnsupportedPatchType = newLocalizedError(msgUnsupportedPatchType)
+	errMalformedPatch       = errors.New("malformed patch document")
+	errPatchNotApplicable   = errors.New("patch cannot be applied to the credit card")
+)
+
+type patchableCard struct {
+	Number         string `json:"number"`
+	ExpirationDate string `json:"expiration_date"`
+	Holder         string `json:"holder"`
+	CvvCode        int    `json:"cvv,omitempty"`
+}
+
+func applyCardPatch(card creditCard, contentType string, patch []byte) (cardRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return cardRequest{}, errUnsupportedPatchType
+	}
+
+	maskedNumber := maskPAN(card.Number)
+	doc, err := json.Marshal(patchableCard{
+		Number:         maskedNumber,
+		ExpirationDate: card.ExpirationDate.String(),
+		Holder:         card.Holder,
+	})
+	if err != nil {
+		return cardRequest{}, fmt.Errorf("marshal credit card for patch: %w", err)
+	}
+
+	var patched []byte
+	switch mediaType {
+	case mergePatchContentType:
+		patched, err = jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return cardRequest{}, wrapLocalizedError(errMalformedPatch, msgMalformedPatch, err)
+		}
+	case jsonPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return cardRequest{}, wrapLocalizedError(errMalformedPatch, msgMalformedPatch, err)
+		}
+		if err := checkNumberNotRead(operations); err != nil {
+			return cardRequest{}, err
+		}
+		patched, err = operations.Apply(doc)
+		if err != nil {
+			return cardRequest{}, wrapLocalizedError(errPatchNotApplicable, msgPatchNotApplicable, err)
+		}
+	default:
+		return cardRequest{}, errUnsupportedPatchType
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+
+	var result patchableCard
+	if err := decoder.Decode(&result); err != nil {
+		return cardRequest{}, wrapLocalizedError(errPatchNotApplicable, msgPatchNotApplicable, err)
+	}
+
+	if result.Number == maskedNumber {
+		result.Number = card.Number
+	}
+
+	return cardRequest{
+		Number:         result.Number,
+		ExpirationDate: result.ExpirationDate,
+		Holder:         result.Holder,
+		CvvCode:        result.CvvCode,
+	}, nil
+}
+
+func checkNumberNotRead(operations jsonpatch.Patch) error {
+	for _, operation := range operations {
+		switch operation.Kind() {
+		case "test", "copy", "move":
+		default:
+			continue
+		}
+
+		path, _ := operation.Path()
+		from, _ := operation.From()
+		if path == numberPatchPath || from == numberPatchPath {
+			return wrapLocalizedError(errPatchNotApplicable, msgPatchNumberWriteOnly, operation.Kind())
+		}
+	}
+
+	return nil
+}
+
+func diffCardPatch(card creditCard, req cardRequest) cardPatch {
+	patched := req.card()
+
+	var patch cardPatch
+	if patched.Number != card.Number {
+		patch.Number = &patched.Number
+		patch.Brand = patched.Brand
+		patch.Fingerprint = patched.Fingerprint
+	}
+	if patched.ExpirationDate != card.ExpirationDate {
+		patch.ExpirationDate = &patched.ExpirationDate
+	}
+	if patched.Holder != card.Holder {
+		patch.Holder = &patched.Holder
+	}
+
+	return patch
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_applyCardPatch(t *testing.T) {
+	card := creditCard{
+		ID:             2,
+		Number:         "4263982640269299",
+		ExpirationDate: testExpirationDate("12/43"),
+		Holder:         "Іванко",
+		Brand:          brandVisa,
+		Version:        3,
+	}
+
+	testCases := map[string]struct {
+		contentType string
+		patch       string
+		expReq      cardRequest
+		expErr      error
+	}{
+		"merge_patch_holder": {
+			contentType: mergePatchContentType,
+			patch:       `{"holder":"Петро"}`,
+			expReq:      testCardRequest("4263982640269299", "12/43", "Петро", 0),
+		},
+		"merge_patch_with_charset": {
+			contentType: mergePatchContentType + "; charset=utf-8",
+			patch:       `{"number":"5375414100000000","cvv":337}`,
+			expReq:      testCardRequest("5375414100000000", "12/43", "Іванко", 337),
+		},
+		"merge_patch_masked_number_kept": {
+			contentType: mergePatchContentType,
+			patch:       `{"number":"426398******9299","holder":"Петро"}`,
+			expReq:      testCardRequest("4263982640269299", "12/43", "Петро", 0),
+		},
+		"merge_patch_removes_field": {
+			contentType: mergePatchContentType,
+			patch:       `{"holder":null}`,
+			expReq:      testCardRequest("4263982640269299", "12/43", "", 0),
+		},
+		"merge_patch_read_only_field": {
+			contentType: mergePatchContentType,
+			patch:       `{"id":5}`,
+			expErr:      errPatchNotApplicable,
+		},
+		"merge_patch_malformed": {
+			contentType: mergePatchContentType,
+			patch:       `{"holder":`,
+			expErr:      errMalformedPatch,
+		},
+		"json_patch_replace": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"test","path":"/holder","value":"Іванко"},{"op":"replace","path":"/expiration_date","value":"2031-05"}]`,
+			expReq:      testCardRequest("4263982640269299", "2031-05", "Іванко", 0),
+		},
+		"json_patch_failed_test": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"test","path":"/holder","value":"Петро"},{"op":"replace","path":"/holder","value":"Олег"}]`,
+			expErr:      errPatchNotApplicable,
+		},
+		"json_patch_replace_after_masked_number": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"replace","path":"/holder","value":"Петро"}]`,
+			expReq:      testCardRequest("4263982640269299", "12/43", "Петро", 0),
+		},
+		"json_patch_copy_number": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"copy","from":"/number","path":"/holder"}]`,
+			expErr:      errPatchNotApplicable,
+		},
+		"json_patch_move_number": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"move","from":"/number","path":"/holder"}]`,
+			expErr:      errPatchNotApplicable,
+		},
+		"json_patch_test_number": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"test","path":"/number","value":"4263982640269299"}]`,
+			expErr:      errPatchNotApplicable,
+		},
+		"json_patch_copy_onto_number": {
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"copy","from":"/holder","path":"/number"}]`,
+			expErr:      errPatchNotApplicable,
+		},
+		"json_patch_malformed": {
+			contentType: jsonPatchContentType,
+			patch:       `{"op":"replace"}`,
+			expErr:      errMalformedPatch,
+		},
+		"unsupported_content_type": {
+			contentType: "application/json",
+			patch:       `{"holder":"Петро"}`,
+			expErr:      errUnsupportedPatchType,
+		},
+		"missing_content_type": {
+			patch:  `{"holder":"Петро"}`,
+			expErr: errUnsupportedPatchType,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := applyCardPatch(card, tc.contentType, []byte(tc.patch))
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expReq, req)
+		})
+	}
+}
+
+func Test_diffCardPatch(t *testing.T) {
+	card := creditCard{
+		ID:             2,
+		Number:         "4263982640269299",
+		ExpirationDate: testExpirationDate("12/43"),
+		Holder:         "Іванко",
+		Brand:          brandVisa,
+	}
+
+	unchanged := testCardRequest("4263982640269299", "2043-12", "Іванко", 0)
+	assert.True(t, diffCardPatch(card, unchanged).isEmpty())
+
+	renamed := testCardRequest("4263982640269299", "12/43", "Петро", 0)
+	patch := diffCardPatch(card, renamed)
+	assert.Nil(t, patch.Number)
+	assert.Nil(t, patch.ExpirationDate)
+	require.NotNil(t, patch.Holder)
+	assert.Equal(t, "Петро", *patch.Holder)
+
+	renumbered := testCardRequest("5375414100000000", "12/43", "Іванко", 337)
+	renumbered.Brand = brandMastercard
+	renumbered.Fingerprint = []byte("mastercard")
+	patch = diffCardPatch(card, renumbered)
+	require.NotNil(t, patch.Number)
+	assert.Equal(t, "5375414100000000", *patch.Number)
+	assert.Equal(t, brandMastercard, patch.Brand)
+	assert.Equal(t, []byte("mastercard"), patch.Fingerprint)
+	assert.Nil(t, patch.Holder)
+}
+
+func testCardRequest(number, expiration, holder string, cvv int) cardRequest {
+	return cardRequest{
+		Number:         number,
+		ExpirationDate: expiration,
+		Holder:         holder,
+		CvvCode:        cvv,
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const problemContentType = "application/problem+json"
+const problemTypePrefix = "/problems/"
+
+type problemKind struct {
+	Status int
+	Type   string
+	Title  messageKey
+}
+
+var (
+	problemMalformedRequest         = problemKind{Status: http.StatusBadRequest, Type: "malformed-request", Title: msgTitleMalformedRequest}
+	problemValidationFailed         = problemKind{Status: http.StatusBadRequest, Type: "validation-failed", Title: msgTitleValidationFailed}
+	problemInvalidQuery             = problemKind{Status: http.StatusBadRequest, Type: "invalid-query", Title: msgTitleInvalidQuery}
+	problemUnauthorized             = problemKind{Status: http.StatusUnauthorized, Type: "unauthorized", Title: msgTitleUnauthorized}
+	problemForbidden                = problemKind{Status: http.StatusForbidden, Type: "forbidden", Title: msgTitleForbidden}
+	problemCountryNotAllowed        = problemKind{Status: http.StatusForbidden, Type: "country-not-allowed", Title: msgTitleCountryNotAllowed}
+	problemNotFound                 = problemKind{Status: http.StatusNotFound, Type: "not-found", Title: msgTitleNotFound}
+	problemMethodNotAllowed         = problemKind{Status: http.StatusMethodNotAllowed, Type: "method-not-allowed", Title: msgTitleMethodNotAllowed}
+	problemCardNotFound             = problemKind{Status: http.StatusNotFound, Type: "card-not-found", Title: msgTitleCardNotFound}
+	problemAPIKeyNotFound           = problemKind{Status: http.StatusNotFound, Type: "api-key-not-found", Title: msgTitleAPIKeyNotFound}
+	problemCardNotDeleted           = problemKind{Status: http.StatusConflict, Type: "card-not-deleted", Title: msgTitleCardNotDeleted}
+	problemDuplicateCard            = problemKind{Status: http.StatusConflict, Type: "duplicate-card", Title: msgTitleDuplicateCard}
+	problemIdempotencyKeyInProgress = problemKind{Status: http.StatusConflict, Type: "idempotency-key-in-progress", Title: msgTitleIdempotencyKeyInProgress}
+	problemVersionConflict          = problemKind{Status: http.StatusPreconditionFailed, Type: "version-conflict", Title: msgTitleVersionConflict}
+	problemUnsupportedMediaType     = problemKind{Status: http.StatusUnsupportedMediaType, Type: "unsupported-media-type", Title: msgTitleUnsupportedMediaType}
+	problemCardVerificationFailed   = problemKind{Status: http.StatusUnprocessableEntity, Type: "card-verification-failed", Title: msgTitleCardVerificationFailed}
+	problemPatchNotApplicable       = problemKind{Status: http.StatusUnprocessableEntity, Type: "patch-not-applicable", Title: msgTitlePatchNotApplicable}
+	problemIdempotencyKeyReused     = problemKind{Status: http.StatusUnprocessableEntity, Type: "idempotency-key-reused", Title: msgTitleIdempotencyKeyReused}
+	problemPreconditionRequired     = problemKind{Status: http.StatusPreconditionRequired, Type: "precondition-required", Title: msgTitlePreconditionRequired}
+	problemRateLimited              = problemKind{Status: http.StatusTooManyRequests, Type: "rate-limited", Title: msgTitleRateLimited}
+	problemInternalError            = problemKind{Status: http.StatusInternalServerError, Type: "internal-error", Title: msgTitleInternalError}
+	problemRequestCancelled         = problemKind{Status: http.StatusServiceUnavailable, Type: "request-cancelled", Title: msgTitleRequestCancelled}
+	problemDeadlineExceeded         = problemKind{Status: http.StatusGatewayTimeout, Type: "deadline-exceeded", Title: msgTitleDeadlineExceeded}
+)
+
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+func newProblem(r *http.Request, kind problemKind, detail string, fieldErrors map[string]string) problem {
+	return problem{
+		Type:      problemTypePrefix + kind.Type,
+		Title:     localize(r, kind.Title),
+		Status:    kind.Status,
+		Detail:    detail,
+		RequestID: requestID(r),
+		Errors:    fieldErrors,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, kind problemKind, detail string) {
+	writeFieldProblem(w, r, kind, detail, nil)
+}
+
+func writeFieldProblem(w http.ResponseWriter, r *http.Request, kind problemKind, detail string, fieldErrors map[string]string) {
+	resp, err := json.Marshal(newProblem(r, kind, detail, fieldErrors))
+	if err != nil {
+		requestLogger(r).Error("marshal problem", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(kind.Status)
+	w.Write(resp)
+}
+
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrors validation.Errors
+	if !errors.As(err, &validationErrors) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	fieldErrors := make(map[string]string, len(validationErrors))
+	for field, fieldErr := range validationErrors {
+		fieldErrors[field] = fieldErr.Error()
+	}
+
+	writeFieldProblem(w, r, problemValidationFailed, "", fieldErrors)
+}
+
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).Error("internal error", slog.Int("status", problemInternalError.Status), slog.Any("error", err))
+	writeProblem(w, r, problemInternalError, "")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func testProblem(kind problemKind, detail string, fieldErrors map[string]string) string {
+	resp, err := json.Marshal(problem{
+		Type:   problemTypePrefix + kind.Type,
+		Title:  translate(languageEnglish, kind.Title),
+		Status: kind.Status,
+		Detail: detail,
+		Errors: fieldErrors,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return string(resp)
+}
+
+func Test_writeProblem(t *testing.T) {
+	testCases := map[string]struct {
+		write func(w http.ResponseWriter, r *http.Request)
+
+		expBody       string
+		expStatusCode int
+	}{
+		"with_detail": {
+			write: func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, r, problemForbidden, "revealing card numbers requires the reveal permission")
+			},
+			expBody:       `{"type":"/problems/forbidden","title":"Operation is not permitted","status":403,"detail":"revealing card numbers requires the reveal permission","request_id":"req-1"}`,
+			expStatusCode: http.StatusForbidden,
+		},
+		"validation_errors": {
+			write: func(w http.ResponseWriter, r *http.Request) {
+				writeValidationProblem(w, r, validation.Errors{
+					"holder":          errors.New("cannot be blank"),
+					"expiration_date": errors.New("термін дії картки минув"),
+				})
+			},
+			expBody:       `{"type":"/problems/validation-failed","title":"Request validation failed","status":400,"request_id":"req-1","errors":{"expiration_date":"термін дії картки минув","holder":"cannot be blank"}}`,
+			expStatusCode: http.StatusBadRequest,
+		},
+		"validation_internal_error": {
+			write: func(w http.ResponseWriter, r *http.Request) {
+				writeValidationProblem(w, r, assert.AnError)
+			},
+			expBody:       `{"type":"/problems/internal-error","title":"Internal server error","status":500,"request_id":"req-1"}`,
+			expStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+			request.Header.Set(xRequestIDHeaderKey, "req-1")
+
+			rw := httptest.NewRecorder()
+			requestIDMiddleware(tc.write)(rw, request)
+
+			assert.Equal(t, tc.expBody, rw.Body.String())
+			assert.Equal(t, problemContentType, rw.Header().Get("Content-Type"))
+			assert.Equal(t, "req-1", rw.Header().Get(xRequestIDHeaderKey))
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+		})
+	}
+}
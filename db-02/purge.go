@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+func runPurger(ctx context.Context, logger *slog.Logger, store CardStore, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeDeletedCards(ctx, logger, store, time.Now().Add(-retention))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeDeletedCards(ctx context.Context, logger *slog.Logger, store CardStore, deletedBefore time.Time) {
+	purged, err := store.PurgeDeletedCards(ctx, deletedBefore)
+	if err != nil {
+		logger.Error("purge deleted credit cards", slog.Any("error", err))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("purged deleted credit cards", slog.Int("count", purged))
+	}
+}
+
+func runIdempotencyKeyPurger(ctx context.Context, logger *slog.Logger, store IdempotencyStore, ttl, interval time.Duration) {
+	if ttl <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeIdempotencyKeys(ctx, logger, store, time.Now().Add(-ttl))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeIdempotencyKeys(ctx context.Context, logger *slog.Logger, store IdempotencyStore, createdBefore time.Time) {
+	purged, err := store.PurgeIdempotencyKeys(ctx, createdBefore)
+	if err != nil {
+		logger.Error("purge expired idempotency keys", slog.Any("error", err))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("purged expired idempotency keys", slog.Int("count", purged))
+	}
+}
+
+func runRateLimitPurger(ctx context.Context, logger *slog.Logger, store RateLimitStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeRateLimitBuckets(ctx, logger, store, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeRateLimitBuckets(ctx context.Context, logger *slog.Logger, store RateLimitStore, fullBefore time.Time) {
+	purged, err := store.PurgeRateLimitBuckets(ctx, fullBefore)
+	if err != nil {
+		logger.Error("purge idle rate limit buckets", slog.Any("error", err))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("purged idle rate limit buckets", slog.Int("count", purged))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_runPurger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cutoffs := make(chan time.Time, 1)
+	store := &cardStoreMock{
+		purgeDeletedCardsFunc: func(ctx context.Context, deletedBefore time.Time) (int, error) {
+			cancel()
+			cutoffs <- deletedBefore
+			return 1, nil
+		},
+	}
+
+	var logs bytes.Buffer
+	started := time.Now()
+	runPurger(ctx, newTestLogger(&logs), store, time.Hour, time.Minute)
+
+	cutoff := <-cutoffs
+	assert.WithinDuration(t, started.Add(-time.Hour), cutoff, time.Second)
+	assert.Equal(t, "level=INFO msg=\"purged deleted credit cards\" count=1\n", logs.String())
+}
+
+func Test_runPurger_disabled(t *testing.T) {
+	store := &cardStoreMock{}
+
+	runPurger(context.Background(), discardLogger, store, 0, time.Minute)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var last4Regexp = regexp.MustCompile(`^[0-9]{4}$`)
+
+type sortField struct {
+	Name string
+	Desc bool
+}
+
+type cardSortField struct {
+	column   string
+	key      func(card creditCard) any
+	parseKey func(value string) (any, error)
+}
+
+var cardSortFields = map[string]cardSortField{
+	"id": {
+		column:   "id",
+		key:      func(card creditCard) any { return card.ID },
+		parseKey: func(value string) (any, error) { return strconv.Atoi(value) },
+	},
+	"holder": {
+		column:   "holder_name",
+		key:      func(card creditCard) any { return card.Holder },
+		parseKey: parseStringKey,
+	},
+	"brand": {
+		column:   "brand",
+		key:      func(card creditCard) any { return card.Brand },
+		parseKey: parseStringKey,
+	},
+	"expiration_date": {
+		column:   "expiration_date",
+		key:      func(card creditCard) any { return card.ExpirationDate.Time() },
+		parseKey: parseTimeKey,
+	},
+	"created_at": {
+		column:   "created_at",
+		key:      func(card creditCard) any { return card.CreatedAt },
+		parseKey: parseTimeKey,
+	},
+}
+
+func parseStringKey(value string) (any, error) {
+	return value, nil
+}
+
+func parseTimeKey(value string) (any, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func compareSortKeys(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+
+	panic(fmt.Sprintf("unsupported sort key type %T", a))
+}
+
+func cardSortKeys(card creditCard, sort []sortField) []any {
+	keys := make([]any, 0, len(sort))
+	for _, field := range sort {
+		keys = append(keys, cardSortFields[field.Name].key(card))
+	}
+
+	return keys
+}
+
+func compareCardKeys(aKeys []any, aID int, bKeys []any, bID int, sort []sortField) int {
+	for i, field := range sort {
+		c := compareSortKeys(aKeys[i], bKeys[i])
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(aID, bID)
+}
+
+func parseListCardsQuery(params url.Values) (listCardsQuery, error) {
+	query := listCardsQuery{
+		Holder: params.Get("holder"),
+		Brand:  strings.ToLower(params.Get("brand")),
+		Limit:  defaultPageLimit,
+	}
+
+	if last4 := params.Get("last4"); last4 != "" {
+		if !last4Regexp.MatchString(last4) {
+			return listCardsQuery{}, newLocalizedError(msgQueryLast4Invalid)
+		}
+		query.Last4 = last4
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *expirationDate
+	}{
+		{name: "expiration_date_from", dst: &query.ExpirationFrom},
+		{name: "expiration_date_to", dst: &query.ExpirationTo},
+	} {
+		value := params.Get(param.name)
+		if value == "" {
+			continue
+		}
+		expiration, err := parseExpirationDate(value)
+		if err != nil {
+			return listCardsQuery{}, newLocalizedError(msgQueryExpirationDateInvalid, param.name)
+		}
+		*param.dst = expiration
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{name: "created_at_from", dst: &query.CreatedFrom},
+		{name: "created_at_to", dst: &query.CreatedTo},
+	} {
+		value := params.Get(param.name)
+		if value == "" {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return listCardsQuery{}, newLocalizedError(msgQueryTimestampInvalid, param.name)
+		}
+		*param.dst = createdAt
+	}
+
+	if rawIncludeDeleted := params.Get("include_deleted"); rawIncludeDeleted != "" {
+		includeDeleted, err := strconv.ParseBool(rawIncludeDeleted)
+		if err != nil {
+			return listCardsQuery{}, newLocalizedError(msgQueryIncludeDeletedInvalid)
+		}
+		query.IncludeDeleted = includeDeleted
+	}
+
+	sort, err := parseSort(params.Get("sort"))
+	if err != nil {
+		return listCardsQuery{}, err
+	}
+	query.Sort = sort
+
+	if rawLimit := params.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return listCardsQuery{}, newLocalizedError(msgQueryLimitOutOfRange, maxPageLimit)
+		}
+		query.Limit = limit
+	}
+
+	if rawAfter := params.Get("after"); rawAfter != "" {
+		cursor, err := decodeCursor(rawAfter, query.Sort)
+		if err != nil {
+			return listCardsQuery{}, wrapLocalizedError(err, msgQueryCursorInvalid)
+		}
+		query.After = &cursor
+	}
+
+	return query, nil
+}
+
+func parseSort(value string) ([]sortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var sort []sortField
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+
+		field := sortField{Name: name}
+		if strings.HasPrefix(name, "-") {
+			field = sortField{Name: name[1:], Desc: true}
+		}
+
+		if _, ok := cardSortFields[field.Name]; !ok {
+			return nil, newLocalizedError(msgQueryUnknownSortField, field.Name)
+		}
+		if seen[field.Name] {
+			return nil, newLocalizedError(msgQueryDuplicateSortField, field.Name)
+		}
+		seen[field.Name] = true
+
+		sort = append(sort, field)
+	}
+
+	return sort, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseListCardsQuery(t *testing.T) {
+	testCases := map[string]struct {
+		rawQuery string
+		expQuery listCardsQuery
+		expErr   string
+	}{
+		"defaults": {
+			rawQuery: "",
+			expQuery: listCardsQuery{Limit: defaultPageLimit},
+		},
+		"all_filters": {
+			rawQuery: "holder=Олег&brand=VISA&last4=9299&expiration_date_from=01/25&expiration_date_to=12/30" +
+				"&created_at_from=2024-01-01T00:00:00Z&created_at_to=2024-02-01T00:00:00Z&sort=holder,-expiration_date&limit=10",
+			expQuery: listCardsQuery{
+				Holder:         "Олег",
+				Brand:          brandVisa,
+				Last4:          "9299",
+				ExpirationFrom: testExpirationDate("01/25"),
+				ExpirationTo:   testExpirationDate("12/30"),
+				CreatedFrom:    time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:      time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+				Sort:           []sortField{{Name: "holder"}, {Name: "expiration_date", Desc: true}},
+				Limit:          10,
+			},
+		},
+		"invalid_last4": {
+			rawQuery: "last4=92a9",
+			expErr:   "last4 must be exactly 4 digits",
+		},
+		"iso_expiration_date": {
+			rawQuery: "expiration_date_from=2030-12",
+			expQuery: listCardsQuery{ExpirationFrom: testExpirationDate("12/30"), Limit: defaultPageLimit},
+		},
+		"invalid_expiration_date": {
+			rawQuery: "expiration_date_to=2030/12",
+			expErr:   "expiration_date_to must be a month and year such as MM/YY, MM/YYYY or YYYY-MM",
+		},
+		"invalid_created_at": {
+			rawQuery: "created_at_from=yesterday",
+			expErr:   "created_at_from must be an RFC 3339 timestamp",
+		},
+		"sort_field_not_in_allowlist": {
+			rawQuery: "sort=" + url.QueryEscape("number; DROP TABLE credit_cards"),
+			expErr:   `unknown sort field "number; DROP TABLE credit_cards"`,
+		},
+		"duplicate_sort_field": {
+			rawQuery: "sort=holder,-holder",
+			expErr:   `duplicate sort field "holder"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params, err := url.ParseQuery(tc.rawQuery)
+			assert.NoError(t, err)
+
+			query, err := parseListCardsQuery(params)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expQuery, query)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	rateLimitLimitHeaderKey     = "RateLimit-Limit"
+	rateLimitRemainingHeaderKey = "RateLimit-Remaining"
+	rateLimitResetHeaderKey     = "RateLimit-Reset"
+	rateLimitPolicyHeaderKey    = "RateLimit-Policy"
+	retryAfterHeaderKey         = "Retry-After"
+)
+
+type rateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+type rateLimitBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+	FullAt    time.Time
+}
+
+type rateLimitDecision struct {
+	Allowed    bool
+	Limit      rateLimit
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type RateLimitStore interface {
+	TakeRateLimitToken(ctx context.Context, key string, limit rateLimit, now time.Time) (rateLimitDecision, error)
+	PurgeRateLimitBuckets(ctx context.Context, fullBefore time.Time) (int, error)
+}
+
+func (l rateLimit) enabled() bool {
+	return l.Limit > 0 && l.Window > 0
+}
+
+func (l rateLimit) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens * float64(l.Window) / float64(l.Limit))
+}
+
+func (l rateLimit) take(bucket *rateLimitBucket, now time.Time) (rateLimitBucket, rateLimitDecision) {
+	tokens := float64(l.Limit)
+	if bucket != nil {
+		elapsed := max(now.Sub(bucket.UpdatedAt), 0)
+		tokens = min(tokens, bucket.Tokens+float64(elapsed)*float64(l.Limit)/float64(l.Window))
+	}
+
+	decision := rateLimitDecision{Limit: l}
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.refillTime(1 - tokens)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = l.refillTime(float64(l.Limit) - tokens)
+
+	return rateLimitBucket{Tokens: tokens, UpdatedAt: now, FullAt: now.Add(decision.Reset)}, decision
+}
+
+func rateLimitKey(r *http.Request, pattern string) string {
+	return pattern + " " + requestActor(r)
+}
+
+func clientRateLimitKey(r *http.Request, trustedProxies []netip.Prefix) string {
+	addr, err := clientAddr(r, trustedProxies)
+	if err != nil {
+		return "client ip:" + r.RemoteAddr
+	}
+
+	return "client ip:" + addr.Unmap().String()
+}
+
+func rateLimitMiddleware(store RateLimitStore, limit rateLimit, pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return limitRequests(store, limit, func(r *http.Request) string {
+		return rateLimitKey(r, pattern)
+	}, next)
+}
+
+func clientRateLimitMiddleware(store RateLimitStore, limit rateLimit, trustedProxies []netip.Prefix, next http.HandlerFunc) http.HandlerFunc {
+	return limitRequests(store, limit, func(r *http.Request) string {
+		return clientRateLimitKey(r, trustedProxies)
+	}, next)
+}
+
+func limitRequests(store RateLimitStore, limit rateLimit, key func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	if !limit.enabled() {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision, err := store.TakeRateLimitToken(r.Context(), key(r), limit, time.Now())
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.Header().Set(rateLimitLimitHeaderKey, strconv.Itoa(limit.Limit))
+		w.Header().Set(rateLimitRemainingHeaderKey, strconv.Itoa(decision.Remaining))
+		w.Header().Set(rateLimitResetHeaderKey, strconv.Itoa(ceilSeconds(decision.Reset)))
+		w.Header().Set(rateLimitPolicyHeaderKey, fmt.Sprintf("%d;w=%d", limit.Limit, ceilSeconds(limit.Window)))
+
+		if !decision.Allowed {
+			retryAfter := ceilSeconds(decision.RetryAfter)
+			w.Header().Set(retryAfterHeaderKey, strconv.Itoa(retryAfter))
+			writeProblem(w, r, problemRateLimited, localize(r, msgRateLimitExceeded, limit.Limit, retryAfter))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]rateLimitBucket
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]rateLimitBucket)}
+}
+
+func (s *memoryRateLimitStore) TakeRateLimitToken(ctx context.Context, key string, limit rateLimit, now time.Time) (rateLimitDecision, error) {
+	if err := ctx.Err(); err != nil {
+		return rateLimitDecision{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current *rateLimitBucket
+	if bucket, ok := s.buckets[key]; ok {
+		current = &bucket
+	}
+
+	bucket, decision := limit.take(current, now)
+	if decision.Allowed {
+		s.buckets[key] = bucket
+	}
+
+	return decision, nil
+}
+
+func (s *memoryRateLimitStore) PurgeRateLimitBuckets(ctx context.Context, fullBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for key, bucket := range s.buckets {
+		if bucket.FullAt.Before(fullBefore) {
+			delete(s.buckets, key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
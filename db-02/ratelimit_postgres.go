@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type postgresRateLimitStore struct {
+	db *sql.DB
+}
+
+func newPostgresRateLimitStore(db *sql.DB) *postgresRateLimitStore {
+	return &postgresRateLimitStore{db: db}
+}
+
+func (s *postgresRateLimitStore) TakeRateLimitToken(ctx context.Context, key string, limit rateLimit, now time.Time) (rateLimitDecision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return rateLimitDecision{}, fmt.Errorf("begin take rate limit token: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO rate_limit_buckets(key, tokens, updated_at, full_at) VALUES ($1, $2, $3, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Limit), now.UTC())
+	if err != nil {
+		return rateLimitDecision{}, fmt.Errorf("exec insert rate limit bucket: %w", err)
+	}
+
+	var current rateLimitBucket
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limit_buckets WHERE key=$1 FOR UPDATE", key).
+		Scan(&current.Tokens, &current.UpdatedAt)
+	if err != nil {
+		return rateLimitDecision{}, fmt.Errorf("lock rate limit bucket: %w", err)
+	}
+
+	bucket, decision := limit.take(&current, now)
+	if !decision.Allowed {
+		return decision, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens=$1, updated_at=$2, full_at=$3 WHERE key=$4",
+		bucket.Tokens, bucket.UpdatedAt.UTC(), bucket.FullAt.UTC(), key)
+	if err != nil {
+		return rateLimitDecision{}, fmt.Errorf("exec save rate limit bucket: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rateLimitDecision{}, fmt.Errorf("commit take rate limit token: %w", err)
+	}
+
+	return decision, nil
+}
+
+func (s *postgresRateLimitStore) PurgeRateLimitBuckets(ctx context.Context, fullBefore time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at < $1", fullBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("exec purge rate limit buckets: %w", err)
+	}
+
+	numRowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected on purge rate limit buckets: %w", err)
+	}
+
+	return int(numRowsAffected), nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rateLimitStoreFactory func(t *testing.T) RateLimitStore
+
+func rateLimitStoreBackends() map[string]rateLimitStoreFactory {
+	return map[string]rateLimitStoreFactory{
+		storageBackendMemory: func(t *testing.T) RateLimitStore {
+			return newMemoryRateLimitStore()
+		},
+		storageBackendPostgres: func(t *testing.T) RateLimitStore {
+			return newPostgresRateLimitStore(openTestDB(t))
+		},
+	}
+}
+
+func Test_rateLimitTake(t *testing.T) {
+	limit := rateLimit{Limit: 4, Window: time.Minute}
+
+	testCases := map[string]struct {
+		bucket      *rateLimitBucket
+		now         time.Time
+		expBucket   rateLimitBucket
+		expDecision rateLimitDecision
+	}{
+		"new_bucket": {
+			now:         testClockStart,
+			expBucket:   rateLimitBucket{Tokens: 3, UpdatedAt: testClockStart, FullAt: testClockStart.Add(15 * time.Second)},
+			expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 3, Reset: 15 * time.Second},
+		},
+		"partially_refilled": {
+			bucket:      &rateLimitBucket{Tokens: 0.5, UpdatedAt: testClockStart},
+			now:         testClockStart.Add(15 * time.Second),
+			expBucket:   rateLimitBucket{Tokens: 0.5, UpdatedAt: testClockStart.Add(15 * time.Second), FullAt: testClockStart.Add(67500 * time.Millisecond)},
+			expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 0, Reset: 52500 * time.Millisecond},
+		},
+		"refill_capped_at_limit": {
+			bucket:      &rateLimitBucket{Tokens: 0, UpdatedAt: testClockStart},
+			now:         testClockStart.Add(time.Hour),
+			expBucket:   rateLimitBucket{Tokens: 3, UpdatedAt: testClockStart.Add(time.Hour), FullAt: testClockStart.Add(time.Hour + 15*time.Second)},
+			expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 3, Reset: 15 * time.Second},
+		},
+		"empty_bucket": {
+			bucket:      &rateLimitBucket{Tokens: 0.25, UpdatedAt: testClockStart},
+			now:         testClockStart,
+			expBucket:   rateLimitBucket{Tokens: 0.25, UpdatedAt: testClockStart, FullAt: testClockStart.Add(56250 * time.Millisecond)},
+			expDecision: rateLimitDecision{Limit: limit, Remaining: 0, Reset: 56250 * time.Millisecond, RetryAfter: 11250 * time.Millisecond},
+		},
+		"clock_skew": {
+			bucket:      &rateLimitBucket{Tokens: 2, UpdatedAt: testClockStart.Add(time.Second)},
+			now:         testClockStart,
+			expBucket:   rateLimitBucket{Tokens: 1, UpdatedAt: testClockStart, FullAt: testClockStart.Add(45 * time.Second)},
+			expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 1, Reset: 45 * time.Second},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bucket, decision := limit.take(tc.bucket, tc.now)
+			assert.Equal(t, tc.expBucket, bucket)
+			assert.Equal(t, tc.expDecision, decision)
+		})
+	}
+}
+
+func TestRateLimitStore_TakeRateLimitToken(t *testing.T) {
+	limit := rateLimit{Limit: 2, Window: time.Minute}
+
+	steps := []struct {
+		key         string
+		elapsed     time.Duration
+		expDecision rateLimitDecision
+	}{
+		{key: "alice", expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 1, Reset: 30 * time.Second}},
+		{key: "alice", expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 0, Reset: time.Minute}},
+		{key: "alice", expDecision: rateLimitDecision{Limit: limit, Remaining: 0, Reset: time.Minute, RetryAfter: 30 * time.Second}},
+		{key: "bob", expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 1, Reset: 30 * time.Second}},
+		{key: "alice", elapsed: 15 * time.Second, expDecision: rateLimitDecision{Limit: limit, Remaining: 0, Reset: 45 * time.Second, RetryAfter: 15 * time.Second}},
+		{key: "alice", elapsed: 30 * time.Second, expDecision: rateLimitDecision{Allowed: true, Limit: limit, Remaining: 0, Reset: time.Minute}},
+		{key: "alice", elapsed: 30 * time.Second, expDecision: rateLimitDecision{Limit: limit, Remaining: 0, Reset: time.Minute, RetryAfter: 30 * time.Second}},
+	}
+
+	for backend, newStore := range rateLimitStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			store := newStore(t)
+
+			for i, step := range steps {
+				decision, err := store.TakeRateLimitToken(context.Background(), step.key, limit, testClockStart.Add(step.elapsed))
+				require.NoError(t, err)
+				assert.Equal(t, step.expDecision, decision, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestRateLimitStore_TakeRateLimitTokenConcurrently(t *testing.T) {
+	limit := rateLimit{Limit: 5, Window: time.Hour}
+	const requests = 50
+
+	for backend, newStore := range rateLimitStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			store := newStore(t)
+
+			var wg sync.WaitGroup
+			decisions := make([]rateLimitDecision, requests)
+			errs := make([]error, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					decisions[i], errs[i] = store.TakeRateLimitToken(context.Background(), "alice", limit, testClockStart)
+				}(i)
+			}
+			wg.Wait()
+
+			allowed := 0
+			for i := range decisions {
+				require.NoError(t, errs[i])
+				if decisions[i].Allowed {
+					allowed++
+				}
+			}
+			assert.Equal(t, limit.Limit, allowed)
+		})
+	}
+}
+
+func TestRateLimitStore_PurgeRateLimitBuckets(t *testing.T) {
+	limit := rateLimit{Limit: 2, Window: time.Minute}
+
+	for backend, newStore := range rateLimitStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			_, err := store.TakeRateLimitToken(ctx, "alice", limit, testClockStart)
+			require.NoError(t, err)
+			_, err = store.TakeRateLimitToken(ctx, "alice", limit, testClockStart)
+			require.NoError(t, err)
+			_, err = store.TakeRateLimitToken(ctx, "bob", limit, testClockStart)
+			require.NoError(t, err)
+
+			purged, err := store.PurgeRateLimitBuckets(ctx, testClockStart.Add(45*time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			decision, err := store.TakeRateLimitToken(ctx, "alice", limit, testClockStart)
+			require.NoError(t, err)
+			assert.False(t, decision.Allowed)
+
+			purged, err = store.PurgeRateLimitBuckets(ctx, testClockStart.Add(2*time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			decision, err = store.TakeRateLimitToken(ctx, "alice", limit, testClockStart)
+			require.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		})
+	}
+}
+
+func Test_rateLimitMiddleware(t *testing.T) {
+	limit := rateLimit{Limit: 2, Window: time.Hour}
+
+	testCases := map[string]struct {
+		limit         rateLimit
+		requests      int
+		subject       string
+		expStatusCode int
+		expHeader     map[string]string
+		expBody       string
+	}{
+		"first_request": {
+			limit:         limit,
+			requests:      1,
+			subject:       "alice",
+			expStatusCode: http.StatusAccepted,
+			expHeader: map[string]string{
+				rateLimitLimitHeaderKey:     "2",
+				rateLimitRemainingHeaderKey: "1",
+				rateLimitResetHeaderKey:     "1800",
+				rateLimitPolicyHeaderKey:    "2;w=3600",
+			},
+		},
+		"last_token": {
+			limit:         limit,
+			requests:      2,
+			subject:       "alice",
+			expStatusCode: http.StatusAccepted,
+			expHeader: map[string]string{
+				rateLimitLimitHeaderKey:     "2",
+				rateLimitRemainingHeaderKey: "0",
+				rateLimitResetHeaderKey:     "3600",
+				rateLimitPolicyHeaderKey:    "2;w=3600",
+			},
+		},
+		"limit_exceeded": {
+			limit:         limit,
+			requests:      3,
+			subject:       "alice",
+			expStatusCode: http.StatusTooManyRequests,
+			expHeader: map[string]string{
+				"Content-Type":              problemContentType,
+				rateLimitLimitHeaderKey:     "2",
+				rateLimitRemainingHeaderKey: "0",
+				rateLimitResetHeaderKey:     "3600",
+				rateLimitPolicyHeaderKey:    "2;w=3600",
+				retryAfterHeaderKey:         "1800",
+			},
+			expBody: testProblem(problemRateLimited, "rate limit of 2 requests exceeded, retry in 1800 seconds", nil),
+		},
+		"anonymous_client": {
+			limit:         limit,
+			requests:      3,
+			expStatusCode: http.StatusTooManyRequests,
+			expHeader: map[string]string{
+				"Content-Type":              problemContentType,
+				rateLimitLimitHeaderKey:     "2",
+				rateLimitRemainingHeaderKey: "0",
+				rateLimitResetHeaderKey:     "3600",
+				rateLimitPolicyHeaderKey:    "2;w=3600",
+				retryAfterHeaderKey:         "1800",
+			},
+			expBody: testProblem(problemRateLimited, "rate limit of 2 requests exceeded, retry in 1800 seconds", nil),
+		},
+		"disabled": {
+			limit:         rateLimit{Window: time.Hour},
+			requests:      3,
+			subject:       "alice",
+			expStatusCode: http.StatusAccepted,
+			expHeader:     map[string]string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			handler := rateLimitMiddleware(newMemoryRateLimitStore(), tc.limit, "GET /cards", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+
+			var rw *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+				if tc.subject != "" {
+					request = withPrincipal(request, principal{Subject: tc.subject})
+				}
+
+				rw = httptest.NewRecorder()
+				handler(rw, request)
+			}
+
+			assert.Equal(t, tc.expStatusCode, rw.Code)
+			expHeader := http.Header{}
+			for key, value := range tc.expHeader {
+				expHeader.Set(key, value)
+			}
+			assert.Equal(t, expHeader, rw.Header())
+			assert.Equal(t, tc.expBody, rw.Body.String())
+		})
+	}
+}
+
+func Test_rateLimitMiddleware_separateBuckets(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	limit := rateLimit{Limit: 1, Window: time.Hour}
+	accepted := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	listCards := rateLimitMiddleware(store, limit, "GET /cards", accepted)
+	getCard := rateLimitMiddleware(store, limit, "GET /cards/{id}", accepted)
+
+	send := func(handler http.HandlerFunc, caller principal) int {
+		rw := httptest.NewRecorder()
+		handler(rw, withPrincipal(httptest.NewRequest(http.MethodGet, "/cards", nil), caller))
+		return rw.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, send(listCards, principal{Subject: "alice"}))
+	assert.Equal(t, http.StatusTooManyRequests, send(listCards, principal{Subject: "alice"}))
+	assert.Equal(t, http.StatusAccepted, send(listCards, principal{Subject: "bob"}))
+	assert.Equal(t, http.StatusAccepted, send(listCards, principal{Subject: "alice", APIKeyID: 7}))
+	assert.Equal(t, http.StatusAccepted, send(getCard, principal{Subject: "alice"}))
+}
+
+func Test_clientRateLimitMiddleware(t *testing.T) {
+	limit := rateLimit{Limit: 2, Window: time.Hour}
+	trustedProxies, err := parseTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+
+	handler := clientRateLimitMiddleware(newMemoryRateLimitStore(), limit, trustedProxies, authMiddleware(&tokenVerifier{}, newMemoryAPIKeyStore(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	send := func(remoteAddr, forwardedFor, apiKey string) int {
+		request := httptest.NewRequest(http.MethodGet, "/cards", nil)
+		request.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			request.Header.Set(xForwardedForHeaderKey, forwardedFor)
+		}
+		if apiKey != "" {
+			request.Header.Set(xAPIKeyHeaderKey, apiKey)
+		}
+
+		rw := httptest.NewRecorder()
+		handler(rw, request)
+		return rw.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("203.0.113.7:1234", "", "fake-key-1"))
+	assert.Equal(t, http.StatusUnauthorized, send("203.0.113.7:1235", "", "fake-key-2"))
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:1236", "", "fake-key-3"))
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:1237", "", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:1238", "192.0.2.9", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("198.51.100.4:1234", "", ""))
+
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1:1234", "192.0.2.1", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.2:1234", "192.0.2.1", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:1235", "192.0.2.1", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1:1236", "192.0.2.2", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:1237", "203.0.113.7", ""))
+}
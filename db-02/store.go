@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	errCreditCardNotFound = errors.New("credit card not found")
+	errVersionConflict    = errors.New("credit card version conflict")
+	errCreditCardActive   = errors.New("credit card is not deleted")
+)
+
+type duplicateCardError struct {
+	ExistingID int
+}
+
+func (e *duplicateCardError) Error() string {
+	return fmt.Sprintf("credit card already exists with id %d", e.ExistingID)
+}
+
+type fingerprintedCard struct {
+	id          int
+	owner       string
+	last4       string
+	brand       string
+	fingerprint []byte
+}
+
+type duplicateCardGroup struct {
+	OwnerID string `json:"owner_id"`
+	Last4   string `json:"last4"`
+	Brand   string `json:"brand"`
+	CardIDs []int  `json:"card_ids"`
+}
+
+type cardPatch struct {
+	Number         *string
+	Brand          string
+	Fingerprint    []byte
+	ExpirationDate *expirationDate
+	Holder         *string
+}
+
+func (p cardPatch) isEmpty() bool {
+	return p.Number == nil && p.ExpirationDate == nil && p.Holder == nil
+}
+
+func (p cardPatch) apply(card creditCard) creditCard {
+	if p.Number != nil {
+		card.Number = *p.Number
+		card.Brand = p.Brand
+		card.Fingerprint = p.Fingerprint
+	}
+	if p.ExpirationDate != nil {
+		card.ExpirationDate = *p.ExpirationDate
+	}
+	if p.Holder != nil {
+		card.Holder = *p.Holder
+	}
+
+	return card
+}
+
+type listCardsQuery struct {
+	OwnerID        string
+	Holder         string
+	Brand          string
+	Last4          string
+	ExpirationFrom expirationDate
+	ExpirationTo   expirationDate
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	IncludeDeleted bool
+	Sort           []sortField
+	Limit          int
+	After          *cardCursor
+}
+
+type cardCursor struct {
+	ID     int
+	Values []any
+}
+
+type cardsPage struct {
+	Cards   []creditCard
+	Total   int
+	HasMore bool
+}
+
+type CardStore interface {
+	SaveCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error)
+	GetCard(ctx context.Context, owner string, id int) (creditCard, error)
+	ListCards(ctx context.Context, query listCardsQuery) (cardsPage, error)
+	UpdateCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error)
+	PatchCard(ctx context.Context, owner string, id, version int, patch cardPatch, audit auditEntry) (creditCard, error)
+	DeleteCard(ctx context.Context, owner string, id int, audit auditEntry) error
+	RestoreCard(ctx context.Context, owner string, id int, audit auditEntry) (creditCard, error)
+	PurgeDeletedCards(ctx context.Context, deletedBefore time.Time) (int, error)
+	ListDuplicateCards(ctx context.Context) ([]duplicateCardGroup, error)
+}
+
+func groupDuplicateCards(cards []fingerprintedCard) []duplicateCardGroup {
+	groups := make([]duplicateCardGroup, 0)
+	index := make(map[[2]string]int)
+	for _, card := range cards {
+		if len(card.fingerprint) == 0 {
+			continue
+		}
+
+		key := [2]string{card.owner, string(card.fingerprint)}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, duplicateCardGroup{OwnerID: card.owner, Last4: card.last4, Brand: card.brand})
+		}
+		groups[i].CardIDs = append(groups[i].CardIDs, card.id)
+	}
+
+	return slices.DeleteFunc(groups, func(group duplicateCardGroup) bool {
+		return len(group.CardIDs) < 2
+	})
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryCardStore struct {
+	mu     sync.RWMutex
+	cards  []creditCard
+	lastID int
+	audit  *memoryAuditLog
+	now    func() time.Time
+}
+
+func newMemoryCardStore(audit *memoryAuditLog) *memoryCardStore {
+	return &memoryCardStore{audit: audit, now: time.Now}
+}
+
+func (s *memoryCardStore) SaveCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	if err := ctx.Err(); err != nil {
+		return creditCard{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkDuplicate(card.OwnerID, card.Fingerprint, 0); err != nil {
+		return creditCard{}, err
+	}
+
+	s.lastID++
+	card.ID = s.lastID
+	card.CreatedAt = s.now().UTC()
+	card.Version = 1
+	s.cards = append(s.cards, card)
+	s.audit.record(cardAuditEntry(audit, auditActionCreate, nil, &card))
+
+	return card, nil
+}
+
+func (s *memoryCardStore) GetCard(ctx context.Context, owner string, id int) (creditCard, error) {
+	if err := ctx.Err(); err != nil {
+		return creditCard{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, card := range s.cards {
+		if card.ID == id && card.OwnerID == owner && card.DeletedAt == nil {
+			return card, nil
+		}
+	}
+
+	return creditCard{}, errCreditCardNotFound
+}
+
+func (s *memoryCardStore) ListCards(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+	if err := ctx.Err(); err != nil {
+		return cardsPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]creditCard, 0)
+	for _, card := range s.cards {
+		if matchesListCardsQuery(card, query) {
+			matched = append(matched, card)
+		}
+	}
+
+	slices.SortFunc(matched, func(a, b creditCard) int {
+		return compareCardKeys(cardSortKeys(a, query.Sort), a.ID, cardSortKeys(b, query.Sort), b.ID, query.Sort)
+	})
+
+	page := cardsPage{Cards: make([]creditCard, 0), Total: len(matched)}
+	for _, card := range matched {
+		if query.After != nil && compareCardKeys(cardSortKeys(card, query.Sort), card.ID, query.After.Values, query.After.ID, query.Sort) <= 0 {
+			continue
+		}
+
+		if query.Limit > 0 && len(page.Cards) == query.Limit {
+			page.HasMore = true
+			break
+		}
+
+		page.Cards = append(page.Cards, card)
+	}
+
+	return page, nil
+}
+
+func matchesListCardsQuery(card creditCard, query listCardsQuery) bool {
+	if card.OwnerID != query.OwnerID {
+		return false
+	}
+	if card.DeletedAt != nil && !query.IncludeDeleted {
+		return false
+	}
+	if query.Holder != "" && !strings.Contains(strings.ToLower(card.Holder), strings.ToLower(query.Holder)) {
+		return false
+	}
+	if query.Brand != "" && card.Brand != query.Brand {
+		return false
+	}
+	if query.Last4 != "" && cardLast4(card.Number) != query.Last4 {
+		return false
+	}
+	if !query.ExpirationFrom.IsZero() && card.ExpirationDate.Compare(query.ExpirationFrom) < 0 {
+		return false
+	}
+	if !query.ExpirationTo.IsZero() && card.ExpirationDate.Compare(query.ExpirationTo) > 0 {
+		return false
+	}
+	if !query.CreatedFrom.IsZero() && card.CreatedAt.Before(query.CreatedFrom) {
+		return false
+	}
+	if !query.CreatedTo.IsZero() && card.CreatedAt.After(query.CreatedTo) {
+		return false
+	}
+
+	return true
+}
+
+func (s *memoryCardStore) UpdateCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	if err := ctx.Err(); err != nil {
+		return creditCard{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.cards {
+		if s.cards[i].ID != card.ID || s.cards[i].OwnerID != card.OwnerID || s.cards[i].DeletedAt != nil {
+			continue
+		}
+
+		if card.Version != 0 && card.Version != s.cards[i].Version {
+			return creditCard{}, errVersionConflict
+		}
+
+		if err := s.checkDuplicate(card.OwnerID, card.Fingerprint, card.ID); err != nil {
+			return creditCard{}, err
+		}
+
+		before := s.cards[i]
+		s.cards[i].Number = card.Number
+		s.cards[i].ExpirationDate = card.ExpirationDate
+		s.cards[i].Holder = card.Holder
+		s.cards[i].Brand = card.Brand
+		s.cards[i].Fingerprint = card.Fingerprint
+		s.cards[i].Version++
+		s.audit.record(cardAuditEntry(audit, auditActionUpdate, &before, &s.cards[i]))
+		return s.cards[i], nil
+	}
+
+	return creditCard{}, errCreditCardNotFound
+}
+
+func (s *memoryCardStore) PatchCard(ctx context.Context, owner string, id, version int, patch cardPatch, audit auditEntry) (creditCard, error) {
+	if err := ctx.Err(); err != nil {
+		return creditCard{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.cards {
+		if s.cards[i].ID != id || s.cards[i].OwnerID != owner || s.cards[i].DeletedAt != nil {
+			continue
+		}
+
+		if version != 0 && version != s.cards[i].Version {
+			return creditCard{}, errVersionConflict
+		}
+
+		if patch.Number != nil {
+			if err := s.checkDuplicate(owner, patch.Fingerprint, id); err != nil {
+				return creditCard{}, err
+			}
+		}
+
+		before := s.cards[i]
+		card := patch.apply(before)
+		card.Version++
+		s.cards[i] = card
+		s.audit.record(cardAuditEntry(audit, auditActionUpdate, &before, &card))
+		return card, nil
+	}
+
+	return creditCard{}, errCreditCardNotFound
+}
+
+func (s *memoryCardStore) DeleteCard(ctx context.Context, owner string, id int, audit auditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.cards {
+		if s.cards[i].ID != id || s.cards[i].OwnerID != owner || s.cards[i].DeletedAt != nil {
+			continue
+		}
+
+		before := s.cards[i]
+		deletedAt := s.now().UTC()
+		s.cards[i].DeletedAt = &deletedAt
+		s.cards[i].Version++
+		s.audit.record(cardAuditEntry(audit, auditActionDelete, &before, &s.cards[i]))
+		return nil
+	}
+
+	return errCreditCardNotFound
+}
+
+func (s *memoryCardStore) RestoreCard(ctx context.Context, owner string, id int, audit auditEntry) (creditCard, error) {
+	if err := ctx.Err(); err != nil {
+		return creditCard{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.cards {
+		if s.cards[i].ID != id || s.cards[i].OwnerID != owner {
+			continue
+		}
+
+		if s.cards[i].DeletedAt == nil {
+			return creditCard{}, errCreditCardActive
+		}
+
+		if err := s.checkDuplicate(owner, s.cards[i].Fingerprint, id); err != nil {
+			return creditCard{}, err
+		}
+
+		before := s.cards[i]
+		s.cards[i].DeletedAt = nil
+		s.cards[i].Version++
+		s.audit.record(cardAuditEntry(audit, auditActionRestore, &before, &s.cards[i]))
+		return s.cards[i], nil
+	}
+
+	return creditCard{}, errCreditCardNotFound
+}
+
+func (s *memoryCardStore) PurgeDeletedCards(ctx context.Context, deletedBefore time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.cards[:0]
+	for _, card := range s.cards {
+		if card.DeletedAt != nil && card.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		kept = append(kept, card)
+	}
+
+	purged := len(s.cards) - len(kept)
+	s.cards = kept
+
+	return purged, nil
+}
+
+func (s *memoryCardStore) ListDuplicateCards(ctx context.Context) ([]duplicateCardGroup, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cards := make([]fingerprintedCard, 0, len(s.cards))
+	for _, card := range s.cards {
+		cards = append(cards, fingerprintedCard{id: card.ID, owner: card.OwnerID, last4: cardLast4(card.Number), brand: card.Brand, fingerprint: card.Fingerprint})
+	}
+	slices.SortFunc(cards, func(a, b fingerprintedCard) int { return cmp.Compare(a.id, b.id) })
+
+	return groupDuplicateCards(cards), nil
+}
+
+func (s *memoryCardStore) checkDuplicate(owner string, fingerprint []byte, id int) error {
+	if len(fingerprint) == 0 {
+		return nil
+	}
+
+	for _, card := range s.cards {
+		if card.ID != id && card.OwnerID == owner && card.DeletedAt == nil && bytes.Equal(card.Fingerprint, fingerprint) {
+			return &duplicateCardError{ExistingID: card.ID}
+		}
+	}
+
+	return nil
+}
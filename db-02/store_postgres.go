@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const cardColumns = "id, owner_id, number, number_ciphertext, number_data_key, number_key_id, number_fingerprint, expiration_date, holder_name, brand, created_at, version, deleted_at"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type postgresCardStore struct {
+	db      *sql.DB
+	keyring *keyring
+	audit   *postgresAuditLog
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+func newPostgresCardStore(db *sql.DB, keyring *keyring, audit *postgresAuditLog, logger *slog.Logger) *postgresCardStore {
+	return &postgresCardStore{db: db, keyring: keyring, audit: audit, logger: logger, now: time.Now}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *postgresCardStore) scanCard(row rowScanner) (creditCard, error) {
+	var card creditCard
+	var number, keyID sql.NullString
+	var pan encryptedPAN
+	var expiresOn time.Time
+	var deletedAt sql.NullTime
+	err := row.Scan(&card.ID, &card.OwnerID, &number, &pan.Ciphertext, &pan.DataKey, &keyID, &card.Fingerprint, &expiresOn, &card.Holder, &card.Brand, &card.CreatedAt, &card.Version, &deletedAt)
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	card.Number = number.String
+	if keyID.Valid {
+		pan.KeyID = keyID.String
+		card.Number, err = s.keyring.decryptPAN(pan)
+		if err != nil {
+			return creditCard{}, fmt.Errorf("credit card %d: %w", card.ID, err)
+		}
+	}
+
+	card.ExpirationDate = expirationDateOf(expiresOn)
+	card.CreatedAt = card.CreatedAt.UTC()
+	if deletedAt.Valid {
+		deletedAtUTC := deletedAt.Time.UTC()
+		card.DeletedAt = &deletedAtUTC
+	}
+
+	return card, nil
+}
+
+func (s *postgresCardStore) SaveCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	pan, err := s.keyring.encryptPAN(card.Number)
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	card.CreatedAt = s.now().UTC()
+
+	err = s.inTx(ctx, "save credit card", func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "INSERT INTO credit_cards(owner_id, number_ciphertext, number_data_key, number_key_id, number_fingerprint, expiration_date, holder_name, created_at, brand, last4) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (owner_id, number_fingerprint) WHERE deleted_at IS NULL AND duplicate_of IS NULL DO NOTHING RETURNING id, version",
+			card.OwnerID, pan.Ciphertext, pan.DataKey, pan.KeyID, card.Fingerprint, card.ExpirationDate.Time(), card.Holder, card.CreatedAt, card.Brand, cardLast4(card.Number)).Scan(&card.ID, &card.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.duplicateError(ctx, tx, card.OwnerID, card.Fingerprint, 0)
+		}
+		if err != nil {
+			return fmt.Errorf("exec insert into credit cards: %w", err)
+		}
+
+		_, err = s.audit.record(ctx, tx, cardAuditEntry(audit, auditActionCreate, nil, &card))
+		return err
+	})
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	return card, nil
+}
+
+func (s *postgresCardStore) GetCard(ctx context.Context, owner string, id int) (creditCard, error) {
+	card, err := s.scanCard(s.db.QueryRowContext(ctx, "SELECT "+cardColumns+" FROM credit_cards WHERE id=$1 AND owner_id=$2 AND deleted_at IS NULL", id, owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return creditCard{}, errCreditCardNotFound
+	}
+	if err != nil {
+		return creditCard{}, fmt.Errorf("query credit card: %w", err)
+	}
+
+	return card, nil
+}
+
+func (s *postgresCardStore) ListCards(ctx context.Context, query listCardsQuery) (cardsPage, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(format string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	addCondition("owner_id = $%d", query.OwnerID)
+	if !query.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if query.Holder != "" {
+		addCondition(`LOWER(holder_name) LIKE LOWER($%d) ESCAPE '\'`, "%"+likeEscaper.Replace(query.Holder)+"%")
+	}
+	if query.Brand != "" {
+		addCondition("brand = $%d", query.Brand)
+	}
+	if query.Last4 != "" {
+		addCondition("last4 = $%d", query.Last4)
+	}
+	if !query.ExpirationFrom.IsZero() {
+		addCondition("expiration_date >= $%d", query.ExpirationFrom.Time())
+	}
+	if !query.ExpirationTo.IsZero() {
+		addCondition("expiration_date <= $%d", query.ExpirationTo.Time())
+	}
+	if !query.CreatedFrom.IsZero() {
+		addCondition("created_at >= $%d", query.CreatedFrom.UTC())
+	}
+	if !query.CreatedTo.IsZero() {
+		addCondition("created_at <= $%d", query.CreatedTo.UTC())
+	}
+
+	page := cardsPage{Cards: make([]creditCard, 0)}
+
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM credit_cards"+whereClause(conditions), args...).Scan(&page.Total)
+	if err != nil {
+		return cardsPage{}, fmt.Errorf("count credit cards: %w", err)
+	}
+
+	if query.After != nil {
+		conditions = append(conditions, keysetCondition(query.Sort, *query.After, &args))
+	}
+
+	stmt := "SELECT " + cardColumns + " FROM credit_cards" + whereClause(conditions) + orderByClause(query.Sort)
+	if query.Limit > 0 {
+		args = append(args, query.Limit+1)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return cardsPage{}, fmt.Errorf("query credit cards: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		card, err := s.scanCard(rows)
+		if err != nil {
+			return cardsPage{}, fmt.Errorf("scan credit card: %w", err)
+		}
+
+		page.Cards = append(page.Cards, card)
+	}
+	if err := rows.Err(); err != nil {
+		return cardsPage{}, fmt.Errorf("iterate credit cards: %w", err)
+	}
+
+	if query.Limit > 0 && len(page.Cards) > query.Limit {
+		page.Cards = page.Cards[:query.Limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
+
+func (s *postgresCardStore) UpdateCard(ctx context.Context, card creditCard, audit auditEntry) (creditCard, error) {
+	pan, err := s.keyring.encryptPAN(card.Number)
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	var updated creditCard
+	err = s.inTx(ctx, "update credit card", func(tx *sql.Tx) error {
+		before, err := s.lockActiveCard(ctx, tx, card.OwnerID, card.ID, card.Version)
+		if err != nil {
+			return err
+		}
+
+		if err := s.duplicateError(ctx, tx, card.OwnerID, card.Fingerprint, card.ID); err != nil {
+			return err
+		}
+
+		updated, err = s.scanCard(tx.QueryRowContext(ctx, "UPDATE credit_cards SET number=NULL, number_ciphertext=$1, number_data_key=$2, number_key_id=$3, number_fingerprint=$4, duplicate_of=NULL, expiration_date=$5, holder_name=$6, brand=$7, last4=$8, version=version+1 WHERE id=$9 RETURNING "+cardColumns,
+			pan.Ciphertext, pan.DataKey, pan.KeyID, card.Fingerprint, card.ExpirationDate.Time(), card.Holder, card.Brand, cardLast4(card.Number), card.ID))
+		if err != nil {
+			return fmt.Errorf("exec update into credit cards: %w", err)
+		}
+
+		_, err = s.audit.record(ctx, tx, cardAuditEntry(audit, auditActionUpdate, &before, &updated))
+		return err
+	})
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	return updated, nil
+}
+
+func (s *postgresCardStore) PatchCard(ctx context.Context, owner string, id, version int, patch cardPatch, audit auditEntry) (creditCard, error) {
+	var assignments []string
+	var args []any
+
+	set := func(column string, arg any) {
+		args = append(args, arg)
+		assignments = append(assignments, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+
+	if patch.Number != nil {
+		pan, err := s.keyring.encryptPAN(*patch.Number)
+		if err != nil {
+			return creditCard{}, err
+		}
+
+		assignments = append(assignments, "number=NULL", "duplicate_of=NULL")
+		set("number_ciphertext", pan.Ciphertext)
+		set("number_data_key", pan.DataKey)
+		set("number_key_id", pan.KeyID)
+		set("number_fingerprint", patch.Fingerprint)
+		set("brand", patch.Brand)
+		set("last4", cardLast4(*patch.Number))
+	}
+	if patch.ExpirationDate != nil {
+		set("expiration_date", patch.ExpirationDate.Time())
+	}
+	if patch.Holder != nil {
+		set("holder_name", *patch.Holder)
+	}
+	assignments = append(assignments, "version=version+1")
+
+	args = append(args, id)
+	stmt := fmt.Sprintf("UPDATE credit_cards SET %s WHERE id=$%d RETURNING %s", strings.Join(assignments, ", "), len(args), cardColumns)
+
+	var patched creditCard
+	err := s.inTx(ctx, "patch credit card", func(tx *sql.Tx) error {
+		before, err := s.lockActiveCard(ctx, tx, owner, id, version)
+		if err != nil {
+			return err
+		}
+
+		if patch.Number != nil {
+			if err := s.duplicateError(ctx, tx, owner, patch.Fingerprint, id); err != nil {
+				return err
+			}
+		}
+
+		patched, err = s.scanCard(tx.QueryRowContext(ctx, stmt, args...))
+		if err != nil {
+			return fmt.Errorf("exec patch credit card: %w", err)
+		}
+
+		_, err = s.audit.record(ctx, tx, cardAuditEntry(audit, auditActionUpdate, &before, &patched))
+		return err
+	})
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	return patched, nil
+}
+
+func (s *postgresCardStore) inTx(ctx context.Context, name string, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %s: %w", name, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *postgresCardStore) lockCard(ctx context.Context, tx *sql.Tx, owner string, id int) (creditCard, error) {
+	card, err := s.scanCard(tx.QueryRowContext(ctx, "SELECT "+cardColumns+" FROM credit_cards WHERE id=$1 AND owner_id=$2 FOR UPDATE", id, owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return creditCard{}, errCreditCardNotFound
+	}
+	if err != nil {
+		return creditCard{}, fmt.Errorf("lock credit card: %w", err)
+	}
+
+	return card, nil
+}
+
+func (s *postgresCardStore) lockActiveCard(ctx context.Context, tx *sql.Tx, owner string, id, version int) (creditCard, error) {
+	card, err := s.lockCard(ctx, tx, owner, id)
+	if err != nil {
+		return creditCard{}, err
+	}
+	if card.DeletedAt != nil {
+		return creditCard{}, errCreditCardNotFound
+	}
+	if version != 0 && version != card.Version {
+		return creditCard{}, errVersionConflict
+	}
+
+	return card, nil
+}
+
+func (s *postgresCardStore) duplicateError(ctx context.Context, q sqlQuerier, owner string, fingerprint []byte, id int) error {
+	if len(fingerprint) == 0 {
+		return nil
+	}
+
+	var existingID int
+	err := q.QueryRowContext(ctx, "SELECT id FROM credit_cards WHERE owner_id=$1 AND number_fingerprint=$2 AND id<>$3 AND deleted_at IS NULL ORDER BY id LIMIT 1", owner, fingerprint, id).Scan(&existingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query duplicate credit card: %w", err)
+	}
+
+	return &duplicateCardError{ExistingID: existingID}
+}
+
+func (s *postgresCardStore) DeleteCard(ctx context.Context, owner string, id int, audit auditEntry) error {
+	return s.inTx(ctx, "delete credit card", func(tx *sql.Tx) error {
+		before, err := s.lockActiveCard(ctx, tx, owner, id, 0)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := s.scanCard(tx.QueryRowContext(ctx, "UPDATE credit_cards SET deleted_at=$1, version=version+1 WHERE id=$2 RETURNING "+cardColumns, s.now().UTC(), id))
+		if err != nil {
+			return fmt.Errorf("exec soft delete from credit cards: %w", err)
+		}
+
+		_, err = s.audit.record(ctx, tx, cardAuditEntry(audit, auditActionDelete, &before, &deleted))
+		return err
+	})
+}
+
+func (s *postgresCardStore) RestoreCard(ctx context.Context, owner string, id int, audit auditEntry) (creditCard, error) {
+	var restored creditCard
+	err := s.inTx(ctx, "restore credit card", func(tx *sql.Tx) error {
+		before, err := s.lockCard(ctx, tx, owner, id)
+		if err != nil {
+			return err
+		}
+		if before.DeletedAt == nil {
+			return errCreditCardActive
+		}
+
+		if err := s.duplicateError(ctx, tx, owner, before.Fingerprint, id); err != nil {
+			return err
+		}
+
+		restored, err = s.scanCard(tx.QueryRowContext(ctx, "UPDATE credit_cards SET deleted_at=NULL, duplicate_of=NULL, version=version+1 WHERE id=$1 RETURNING "+cardColumns, id))
+		if err != nil {
+			return fmt.Errorf("exec restore credit card: %w", err)
+		}
+
+		_, err = s.audit.record(ctx, tx, cardAuditEntry(audit, auditActionRestore, &before, &restored))
+		return err
+	})
+	if err != nil {
+		return creditCard{}, err
+	}
+
+	return restored, nil
+}
+
+func (s *postgresCardStore) PurgeDeletedCards(ctx context.Context, deletedBefore time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM credit_cards WHERE deleted_at IS NOT NULL AND deleted_at < $1", deletedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("exec purge deleted credit cards: %w", err)
+	}
+
+	numRowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected on purge: %w", err)
+	}
+
+	return int(numRowsAffected), nil
+}
+
+func (s *postgresCardStore) ListDuplicateCards(ctx context.Context) ([]duplicateCardGroup, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, owner_id, last4, brand, number_fingerprint FROM credit_cards WHERE (owner_id, number_fingerprint) IN (SELECT owner_id, number_fingerprint FROM credit_cards WHERE number_fingerprint IS NOT NULL GROUP BY owner_id, number_fingerprint HAVING COUNT(*) > 1) ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("query duplicate credit cards: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []fingerprintedCard
+	for rows.Next() {
+		var card fingerprintedCard
+		if err := rows.Scan(&card.id, &card.owner, &card.last4, &card.brand, &card.fingerprint); err != nil {
+			return nil, fmt.Errorf("scan duplicate credit card: %w", err)
+		}
+
+		cards = append(cards, card)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate duplicate credit cards: %w", err)
+	}
+
+	return groupDuplicateCards(cards), nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func orderByClause(sort []sortField) string {
+	terms := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		term := cardSortFields[field.Name].column
+		if field.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	terms = append(terms, "id")
+
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+func keysetCondition(sort []sortField, cursor cardCursor, args *[]any) string {
+	placeholder := func(value any) string {
+		*args = append(*args, value)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+
+	var alternatives []string
+	var equalities []string
+	for i, field := range sort {
+		column := cardSortFields[field.Name].column
+
+		op := ">"
+		if field.Desc {
+			op = "<"
+		}
+
+		alternative := append(slices.Clone(equalities), fmt.Sprintf("(%s) %s %s", column, op, placeholder(cursor.Values[i])))
+		alternatives = append(alternatives, "("+strings.Join(alternative, " AND ")+")")
+		equalities = append(equalities, fmt.Sprintf("(%s) = %s", column, placeholder(cursor.Values[i])))
+	}
+
+	last := append(equalities, "id > "+placeholder(cursor.ID))
+	alternatives = append(alternatives, "("+strings.Join(last, " AND ")+")")
+
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
@@ -0,0 +1,1273 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"modernc.org/sqlite"
+)
+
+const sqliteCardsSchema = `
+CREATE TABLE credit_cards
+(
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id          VARCHAR(255) NOT NULL DEFAULT '',
+    number            VARCHAR(255) NULL,
+    number_ciphertext BLOB         NULL,
+    number_data_key   BLOB         NULL,
+    number_key_id     VARCHAR(64)  NULL,
+    number_fingerprint BLOB        NULL,
+    duplicate_of      INT          NULL,
+    expiration_date   DATE         NOT NULL,
+    holder_name       VARCHAR(255) NOT NULL,
+    created_at        TIMESTAMP    NOT NULL,
+    brand             VARCHAR(32)  NOT NULL,
+    last4             VARCHAR(4)   NOT NULL,
+    version           INT          NOT NULL DEFAULT 1,
+    deleted_at        TIMESTAMP    NULL
+);
+
+CREATE UNIQUE INDEX credit_cards_number_fingerprint_key ON credit_cards (owner_id, number_fingerprint)
+    WHERE deleted_at IS NULL AND duplicate_of IS NULL;
+
+CREATE TABLE credit_card_audit_log
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    card_id      INT          NOT NULL,
+    owner_id     VARCHAR(255) NOT NULL DEFAULT '',
+    action       VARCHAR(16)  NOT NULL,
+    before       TEXT         NULL,
+    after        TEXT         NULL,
+    actor        VARCHAR(255) NOT NULL,
+    country_code VARCHAR(2)   NOT NULL,
+    created_at   TIMESTAMP    NOT NULL
+);
+
+CREATE TABLE api_keys
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         VARCHAR(100) NOT NULL,
+    owner_id     VARCHAR(255) NOT NULL,
+    scopes       TEXT         NOT NULL,
+    key_hash     BLOB         NOT NULL UNIQUE,
+    expires_at   TIMESTAMP    NULL,
+    last_used_at TIMESTAMP    NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    revoked_at   TIMESTAMP    NULL
+);
+
+CREATE TABLE idempotency_keys
+(
+    key             TEXT         NOT NULL PRIMARY KEY,
+    request_hash    VARCHAR(64)  NOT NULL,
+    status_code     INT          NULL,
+    response_header TEXT         NULL,
+    response_body   BLOB         NULL,
+    created_at      TIMESTAMP    NOT NULL
+);
+
+CREATE TABLE rate_limit_buckets
+(
+    key        TEXT      NOT NULL PRIMARY KEY,
+    tokens     REAL      NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    full_at    TIMESTAMP NOT NULL
+);
+`
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return args[0], nil
+		}
+
+		return strings.ToLower(s), nil
+	})
+
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register("sqlite-test", sqliteTestDriver{driver: db.Driver()})
+}
+
+type sqliteTestDriver struct {
+	driver driver.Driver
+}
+
+var sqliteTestTxLocks sync.Map
+
+func (d sqliteTestDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	txLock, _ := sqliteTestTxLocks.LoadOrStore(name, &sync.Mutex{})
+	return &sqliteTestConn{conn: conn, txLock: txLock.(*sync.Mutex)}, nil
+}
+
+type sqliteTestConn struct {
+	conn   driver.Conn
+	txLock *sync.Mutex
+}
+
+func sqliteQuery(query string) string {
+	return strings.ReplaceAll(query, " FOR UPDATE", "")
+}
+
+func (c *sqliteTestConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(sqliteQuery(query))
+}
+
+func (c *sqliteTestConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.(driver.ConnPrepareContext).PrepareContext(ctx, sqliteQuery(query))
+}
+
+func (c *sqliteTestConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, sqliteQuery(query), args)
+}
+
+func (c *sqliteTestConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, sqliteQuery(query), args)
+}
+
+func (c *sqliteTestConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqliteTestConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.txLock.Lock()
+	tx, err := c.conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		c.txLock.Unlock()
+		return nil, err
+	}
+
+	return &sqliteTestTx{tx: tx, unlock: c.txLock.Unlock}, nil
+}
+
+func (c *sqliteTestConn) Close() error {
+	return c.conn.Close()
+}
+
+type sqliteTestTx struct {
+	tx     driver.Tx
+	unlock func()
+}
+
+func (t *sqliteTestTx) Commit() error {
+	defer t.unlock()
+	return t.tx.Commit()
+}
+
+func (t *sqliteTestTx) Rollback() error {
+	defer t.unlock()
+	return t.tx.Rollback()
+}
+
+type cardStoreFactory func(t *testing.T) CardStore
+
+func cardStoreBackends() map[string]cardStoreFactory {
+	return map[string]cardStoreFactory{
+		storageBackendMemory: func(t *testing.T) CardStore {
+			return &memoryCardStore{audit: &memoryAuditLog{now: newTestClock()}, now: newTestClock()}
+		},
+		storageBackendPostgres: func(t *testing.T) CardStore {
+			db := openTestDB(t)
+			return &postgresCardStore{db: db, audit: &postgresAuditLog{db: db, now: newTestClock()}, keyring: newTestKeyring(t, "k1"), logger: discardLogger, now: newTestClock()}
+		},
+	}
+}
+
+var testClockStart = time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestClock() func() time.Time {
+	calls := 0
+	return func() time.Time {
+		now := testCreatedAt(calls + 1)
+		calls++
+		return now
+	}
+}
+
+func testCreatedAt(id int) time.Time {
+	return testClockStart.Add(time.Duration(id-1) * time.Hour)
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite-test", fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_")))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(sqliteCardsSchema)
+	require.NoError(t, err)
+
+	return db
+}
+
+func seedCards(t *testing.T, store CardStore, cards []creditCard) {
+	t.Helper()
+
+	for _, card := range cards {
+		_, err := store.SaveCard(context.Background(), card, auditEntry{})
+		require.NoError(t, err)
+	}
+}
+
+func listAllCards(t *testing.T, store CardStore) []creditCard {
+	t.Helper()
+
+	page, err := store.ListCards(context.Background(), listCardsQuery{})
+	require.NoError(t, err)
+
+	return page.Cards
+}
+
+func TestCardStore_SaveCard(t *testing.T) {
+	testCases := map[string]struct {
+		setupCards []creditCard
+		card       creditCard
+		expCard    creditCard
+		expCards   []creditCard
+	}{
+		"ok_empty_storage": {
+			setupCards: nil,
+			card: creditCard{
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Іванко",
+				Brand:          brandVisa,
+			},
+			expCard: creditCard{
+				ID:             1,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Іванко",
+				Brand:          brandVisa,
+				CreatedAt:      testCreatedAt(1),
+				Version:        1,
+			},
+			expCards: []creditCard{{
+				ID:             1,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Іванко",
+				Brand:          brandVisa,
+				CreatedAt:      testCreatedAt(1),
+				Version:        1,
+			}},
+		},
+		"ok_with_records_in_storage": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("12/43"),
+					Holder:         "Петрик",
+				},
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("12/43"),
+					Holder:         "Світланка",
+				},
+			},
+			card: creditCard{
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Іванко",
+			},
+			expCard: creditCard{
+				ID:             3,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Іванко",
+				CreatedAt:      testCreatedAt(3),
+				Version:        1,
+			},
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("12/43"),
+					Holder:         "Петрик",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+				{
+					ID:             2,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("12/43"),
+					Holder:         "Світланка",
+					CreatedAt:      testCreatedAt(2),
+					Version:        1,
+				},
+				{
+					ID:             3,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("12/43"),
+					Holder:         "Іванко",
+					CreatedAt:      testCreatedAt(3),
+					Version:        1,
+				},
+			},
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, tc.setupCards)
+
+				gotCard, err := store.SaveCard(context.Background(), tc.card, auditEntry{})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expCard, gotCard)
+
+				assert.ElementsMatch(t, tc.expCards, listAllCards(t, store))
+			})
+		}
+	}
+}
+
+func TestCardStore_GetCard(t *testing.T) {
+	setupCards := []creditCard{
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Петрик",
+		},
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("11/44"),
+			Holder:         "Світланка",
+		},
+	}
+
+	testCases := map[string]struct {
+		cardID  int
+		expCard creditCard
+		expErr  error
+	}{
+		"success": {
+			cardID: 2,
+			expCard: creditCard{
+				ID:             2,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("11/44"),
+				Holder:         "Світланка",
+				CreatedAt:      testCreatedAt(2),
+				Version:        1,
+			},
+		},
+		"record_not_found": {
+			cardID: 83,
+			expErr: errCreditCardNotFound,
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				gotCard, err := store.GetCard(context.Background(), "", tc.cardID)
+				assert.ErrorIs(t, err, tc.expErr)
+				assert.Equal(t, tc.expCard, gotCard)
+			})
+		}
+	}
+}
+
+func TestCardStore_ListCards(t *testing.T) {
+	testCases := map[string]struct {
+		setupCards []creditCard
+		holder     string
+		expCards   []creditCard
+	}{
+		"empty_storage": {
+			setupCards: nil,
+			holder:     "",
+			expCards:   []creditCard{},
+		},
+		"ok_one_record_in_storage": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+				},
+			},
+			holder: "",
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+			},
+		},
+		"equal filter by holder": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+				},
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Петрик",
+				},
+			},
+			holder: "Іванко",
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+			},
+		},
+		"case insensitive filter by holder": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+				},
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Петрик",
+				},
+			},
+			holder: "івАнко",
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+			},
+		},
+		"contains filter by holder": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко Чорногузко",
+				},
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Петрик Чорновуско",
+				},
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Не Я",
+				},
+			},
+			holder: "чорНо",
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко Чорногузко",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+				{
+					ID:             2,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Петрик Чорновуско",
+					CreatedAt:      testCreatedAt(2),
+					Version:        1,
+				},
+			},
+		},
+		"no_matches_by_holder": {
+			setupCards: []creditCard{
+				{
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("21 січня 2023р"),
+					Holder:         "Іванко",
+				},
+			},
+			holder:   "Олег",
+			expCards: []creditCard{},
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, tc.setupCards)
+
+				page, err := store.ListCards(context.Background(), listCardsQuery{Holder: tc.holder})
+				require.NoError(t, err)
+				assert.Equal(t, tc.expCards, page.Cards)
+				assert.Equal(t, len(tc.expCards), page.Total)
+				assert.False(t, page.HasMore)
+			})
+		}
+	}
+}
+
+func TestCardStore_ListCardsPagination(t *testing.T) {
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко Чорногузко"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Петрик"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко Сірко"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко Бурко"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Світланка"},
+	}
+
+	testCases := map[string]struct {
+		query      listCardsQuery
+		expIDs     []int
+		expTotal   int
+		expHasMore bool
+	}{
+		"first_page": {
+			query:      listCardsQuery{Limit: 2},
+			expIDs:     []int{1, 2},
+			expTotal:   5,
+			expHasMore: true,
+		},
+		"middle_page": {
+			query:      listCardsQuery{Limit: 2, After: &cardCursor{ID: 2}},
+			expIDs:     []int{3, 4},
+			expTotal:   5,
+			expHasMore: true,
+		},
+		"last_page": {
+			query:      listCardsQuery{Limit: 2, After: &cardCursor{ID: 4}},
+			expIDs:     []int{5},
+			expTotal:   5,
+			expHasMore: false,
+		},
+		"exact_last_page": {
+			query:      listCardsQuery{Limit: 1, After: &cardCursor{ID: 4}},
+			expIDs:     []int{5},
+			expTotal:   5,
+			expHasMore: false,
+		},
+		"cursor_past_end": {
+			query:      listCardsQuery{Limit: 2, After: &cardCursor{ID: 5}},
+			expIDs:     []int{},
+			expTotal:   5,
+			expHasMore: false,
+		},
+		"filtered_by_holder": {
+			query:      listCardsQuery{Holder: "іванко", Limit: 2, After: &cardCursor{ID: 1}},
+			expIDs:     []int{3, 4},
+			expTotal:   3,
+			expHasMore: false,
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				page, err := store.ListCards(context.Background(), tc.query)
+				require.NoError(t, err)
+
+				assert.Equal(t, tc.expIDs, cardIDs(page.Cards))
+				assert.Equal(t, tc.expTotal, page.Total)
+				assert.Equal(t, tc.expHasMore, page.HasMore)
+			})
+		}
+	}
+}
+
+func TestCardStore_ListCardsFiltersAndSort(t *testing.T) {
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Олег", Brand: brandVisa},
+		{Number: "5555555555554444", ExpirationDate: testExpirationDate("01/30"), Holder: "Андрій", Brand: brandMastercard},
+		{Number: "4111111111111111", ExpirationDate: testExpirationDate("06/27"), Holder: "Олег", Brand: brandVisa},
+		{Number: "378282246310005", ExpirationDate: testExpirationDate("12/43"), Holder: "Богдан", Brand: brandAmex},
+		{Number: "2223003122003222", ExpirationDate: testExpirationDate("03/29"), Holder: "Андрій", Brand: brandMastercard},
+	}
+
+	testCases := map[string]struct {
+		query  listCardsQuery
+		expIDs []int
+	}{
+		"by_brand": {
+			query:  listCardsQuery{Brand: brandMastercard},
+			expIDs: []int{2, 5},
+		},
+		"by_last4": {
+			query:  listCardsQuery{Last4: "1111"},
+			expIDs: []int{3},
+		},
+		"by_expiration_range": {
+			query:  listCardsQuery{ExpirationFrom: testExpirationDate("06/27"), ExpirationTo: testExpirationDate("01/30")},
+			expIDs: []int{2, 3, 5},
+		},
+		"by_created_at_range": {
+			query:  listCardsQuery{CreatedFrom: testCreatedAt(2), CreatedTo: testCreatedAt(4)},
+			expIDs: []int{2, 3, 4},
+		},
+		"combined_filters": {
+			query:  listCardsQuery{Brand: brandVisa, Holder: "олег", ExpirationFrom: testExpirationDate("01/40")},
+			expIDs: []int{1},
+		},
+		"holder_percent_is_literal": {
+			query:  listCardsQuery{Holder: "%"},
+			expIDs: []int{},
+		},
+		"holder_underscore_is_literal": {
+			query:  listCardsQuery{Holder: "О_ег"},
+			expIDs: []int{},
+		},
+		"holder_backslash_is_literal": {
+			query:  listCardsQuery{Holder: `\`},
+			expIDs: []int{},
+		},
+		"sort_by_holder_then_expiration_desc": {
+			query:  listCardsQuery{Sort: []sortField{{Name: "holder"}, {Name: "expiration_date", Desc: true}}},
+			expIDs: []int{2, 5, 4, 1, 3},
+		},
+		"sort_by_created_at_desc": {
+			query:  listCardsQuery{Sort: []sortField{{Name: "created_at", Desc: true}}},
+			expIDs: []int{5, 4, 3, 2, 1},
+		},
+		"sort_by_brand_with_id_tiebreak": {
+			query:  listCardsQuery{Sort: []sortField{{Name: "brand"}}},
+			expIDs: []int{4, 2, 5, 1, 3},
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				page, err := store.ListCards(context.Background(), tc.query)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expIDs, cardIDs(page.Cards))
+				assert.Equal(t, len(tc.expIDs), page.Total)
+			})
+
+			t.Run(backend+"/"+name+"/paginated", func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				query := tc.query
+				query.Limit = 2
+
+				gotIDs := make([]int, 0)
+				for {
+					page, err := store.ListCards(context.Background(), query)
+					require.NoError(t, err)
+					require.Equal(t, len(tc.expIDs), page.Total)
+
+					gotIDs = append(gotIDs, cardIDs(page.Cards)...)
+					if !page.HasMore {
+						break
+					}
+
+					cursor := cardCursorFor(page.Cards[len(page.Cards)-1], query.Sort)
+					query.After = &cursor
+				}
+
+				assert.Equal(t, tc.expIDs, gotIDs)
+			})
+		}
+	}
+}
+
+func cardIDs(cards []creditCard) []int {
+	ids := make([]int, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+	}
+
+	return ids
+}
+
+func TestCardStore_UpdateCard(t *testing.T) {
+	setupCards := []creditCard{
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Петрик",
+		},
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Світланка",
+		},
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Іванко",
+		},
+	}
+
+	unchangedCards := []creditCard{
+		{
+			ID:             1,
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Петрик",
+			CreatedAt:      testCreatedAt(1),
+			Version:        1,
+		},
+		{
+			ID:             2,
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Світланка",
+			CreatedAt:      testCreatedAt(2),
+			Version:        1,
+		},
+		{
+			ID:             3,
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("12/43"),
+			Holder:         "Іванко",
+			CreatedAt:      testCreatedAt(3),
+			Version:        1,
+		},
+	}
+
+	updatedCard := creditCard{
+		ID:             2,
+		Number:         "4263982640269299",
+		ExpirationDate: testExpirationDate("12/43"),
+		Holder:         "Петро",
+		CreatedAt:      testCreatedAt(2),
+		Version:        2,
+	}
+
+	deletedAt := testClockStart.Add(time.Hour)
+
+	testCases := map[string]struct {
+		card     creditCard
+		expCard  creditCard
+		expCards []creditCard
+		expErr   error
+	}{
+		"success_without_precondition": {
+			card: creditCard{
+				ID:             2,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+			},
+			expCard:  updatedCard,
+			expCards: []creditCard{unchangedCards[0], updatedCard, unchangedCards[2]},
+			expErr:   nil,
+		},
+		"success_with_matching_version": {
+			card: creditCard{
+				ID:             2,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+				Version:        1,
+			},
+			expCard:  updatedCard,
+			expCards: []creditCard{unchangedCards[0], updatedCard, unchangedCards[2]},
+			expErr:   nil,
+		},
+		"read_only_fields_kept": {
+			card: creditCard{
+				ID:             2,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+				CreatedAt:      testClockStart,
+				DeletedAt:      &deletedAt,
+			},
+			expCard:  updatedCard,
+			expCards: []creditCard{unchangedCards[0], updatedCard, unchangedCards[2]},
+			expErr:   nil,
+		},
+		"stale_version": {
+			card: creditCard{
+				ID:             2,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+				Version:        7,
+			},
+			expCards: unchangedCards,
+			expErr:   errVersionConflict,
+		},
+		"record_not_found": {
+			card: creditCard{
+				ID:             5,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+			},
+			expCards: unchangedCards,
+			expErr:   errCreditCardNotFound,
+		},
+		"record_not_found_with_version": {
+			card: creditCard{
+				ID:             5,
+				Number:         "4263982640269299",
+				ExpirationDate: testExpirationDate("12/43"),
+				Holder:         "Петро",
+				Version:        1,
+			},
+			expCards: unchangedCards,
+			expErr:   errCreditCardNotFound,
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				gotCard, gotErr := store.UpdateCard(context.Background(), tc.card, auditEntry{})
+				assert.ErrorIs(t, gotErr, tc.expErr)
+				assert.Equal(t, tc.expCard, gotCard)
+
+				assert.Equal(t, tc.expCards, listAllCards(t, store))
+			})
+		}
+	}
+}
+
+func TestCardStore_PatchCard(t *testing.T) {
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Петрик", Brand: brandVisa, Fingerprint: []byte("visa")},
+		{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Світланка", Brand: brandMastercard, Fingerprint: []byte("mastercard")},
+	}
+
+	holder := "Петро"
+	expiration := testExpirationDate("06/35")
+	number := "378282246310005"
+	duplicateNumber := "4263982640269299"
+
+	testCases := map[string]struct {
+		id      int
+		version int
+		patch   cardPatch
+		expCard creditCard
+		expErr  error
+	}{
+		"holder_only": {
+			id:    2,
+			patch: cardPatch{Holder: &holder},
+			expCard: creditCard{
+				ID: 2, Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Петро",
+				Brand: brandMastercard, Fingerprint: []byte("mastercard"), CreatedAt: testCreatedAt(2), Version: 2,
+			},
+		},
+		"number_and_expiration_with_matching_version": {
+			id:      2,
+			version: 1,
+			patch:   cardPatch{Number: &number, Brand: brandAmex, Fingerprint: []byte("amex"), ExpirationDate: &expiration},
+			expCard: creditCard{
+				ID: 2, Number: "378282246310005", ExpirationDate: testExpirationDate("06/35"), Holder: "Світланка",
+				Brand: brandAmex, Fingerprint: []byte("amex"), CreatedAt: testCreatedAt(2), Version: 2,
+			},
+		},
+		"stale_version": {
+			id:      2,
+			version: 7,
+			patch:   cardPatch{Holder: &holder},
+			expErr:  errVersionConflict,
+		},
+		"record_not_found": {
+			id:     5,
+			patch:  cardPatch{Holder: &holder},
+			expErr: errCreditCardNotFound,
+		},
+		"duplicate_number": {
+			id:     2,
+			patch:  cardPatch{Number: &duplicateNumber, Brand: brandVisa, Fingerprint: []byte("visa")},
+			expErr: &duplicateCardError{ExistingID: 1},
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+				before := listAllCards(t, store)
+
+				card, err := store.PatchCard(ctx, "", tc.id, tc.version, tc.patch, auditEntry{})
+				if tc.expErr != nil {
+					assert.Equal(t, tc.expErr, err)
+					assert.Equal(t, before, listAllCards(t, store))
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expCard, card)
+
+				got, err := store.GetCard(ctx, "", tc.id)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expCard, got)
+			})
+		}
+	}
+}
+
+func TestCardStore_DeleteCard(t *testing.T) {
+	setupCards := []creditCard{
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("01/30"),
+			Holder:         "Юра",
+		},
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("02/30"),
+			Holder:         "Олег",
+		},
+		{
+			Number:         "4263982640269299",
+			ExpirationDate: testExpirationDate("03/30"),
+			Holder:         "Григорій",
+		},
+	}
+
+	testCases := map[string]struct {
+		cardID   int
+		expCards []creditCard
+		expErr   error
+	}{
+		"record_not_found": {
+			cardID: 83,
+			expErr: errCreditCardNotFound,
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("01/30"),
+					Holder:         "Юра",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+				{
+					ID:             2,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("02/30"),
+					Holder:         "Олег",
+					CreatedAt:      testCreatedAt(2),
+					Version:        1,
+				},
+				{
+					ID:             3,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("03/30"),
+					Holder:         "Григорій",
+					CreatedAt:      testCreatedAt(3),
+					Version:        1,
+				},
+			},
+		},
+		"success": {
+			cardID: 2,
+			expCards: []creditCard{
+				{
+					ID:             1,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("01/30"),
+					Holder:         "Юра",
+					CreatedAt:      testCreatedAt(1),
+					Version:        1,
+				},
+				{
+					ID:             3,
+					Number:         "4263982640269299",
+					ExpirationDate: testExpirationDate("03/30"),
+					Holder:         "Григорій",
+					CreatedAt:      testCreatedAt(3),
+					Version:        1,
+				},
+			},
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, tc := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+				seedCards(t, store, setupCards)
+
+				err := store.DeleteCard(context.Background(), "", tc.cardID, auditEntry{})
+				assert.ErrorIs(t, err, tc.expErr)
+
+				assert.Equal(t, tc.expCards, listAllCards(t, store))
+			})
+		}
+	}
+}
+
+func TestCardStore_SoftDelete(t *testing.T) {
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Юра"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Олег"},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			seedCards(t, store, setupCards)
+
+			require.NoError(t, store.DeleteCard(ctx, "", 2, auditEntry{}))
+			deletedAt := testCreatedAt(3)
+
+			_, err := store.GetCard(ctx, "", 2)
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			_, err = store.UpdateCard(ctx, creditCard{ID: 2, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Олег"}, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			assert.ErrorIs(t, store.DeleteCard(ctx, "", 2, auditEntry{}), errCreditCardNotFound)
+			assert.Equal(t, []int{1}, cardIDs(listAllCards(t, store)))
+
+			page, err := store.ListCards(ctx, listCardsQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			require.Equal(t, []int{1, 2}, cardIDs(page.Cards))
+			assert.Nil(t, page.Cards[0].DeletedAt)
+			assert.Equal(t, &deletedAt, page.Cards[1].DeletedAt)
+			assert.Equal(t, 2, page.Cards[1].Version)
+
+			_, err = store.RestoreCard(ctx, "", 1, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardActive)
+
+			_, err = store.RestoreCard(ctx, "", 83, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			restored, err := store.RestoreCard(ctx, "", 2, auditEntry{})
+			require.NoError(t, err)
+			assert.Nil(t, restored.DeletedAt)
+			assert.Equal(t, 3, restored.Version)
+			assert.Equal(t, []int{1, 2}, cardIDs(listAllCards(t, store)))
+		})
+	}
+}
+
+func TestCardStore_PurgeDeletedCards(t *testing.T) {
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Юра"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Олег"},
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Григорій"},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			seedCards(t, store, setupCards)
+
+			require.NoError(t, store.DeleteCard(ctx, "", 1, auditEntry{}))
+			require.NoError(t, store.DeleteCard(ctx, "", 3, auditEntry{}))
+
+			purged, err := store.PurgeDeletedCards(ctx, testCreatedAt(5))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			page, err := store.ListCards(ctx, listCardsQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			assert.Equal(t, []int{2, 3}, cardIDs(page.Cards))
+
+			_, err = store.RestoreCard(ctx, "", 1, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			purged, err = store.PurgeDeletedCards(ctx, testCreatedAt(100))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			saved, err := store.SaveCard(ctx, creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Тарас"}, auditEntry{})
+			require.NoError(t, err)
+			assert.Equal(t, 4, saved.ID)
+		})
+	}
+}
+
+func TestCardStore_DuplicateCards(t *testing.T) {
+	visa := []byte("visa-fingerprint")
+	mastercard := []byte("mastercard-fingerprint")
+
+	setupCards := []creditCard{
+		{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Юра", Brand: brandVisa, Fingerprint: visa},
+		{Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Олег", Brand: brandMastercard, Fingerprint: mastercard},
+		{Number: "378282246310005", ExpirationDate: testExpirationDate("02/31"), Holder: "Оксана", Brand: brandAmex},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			seedCards(t, store, setupCards)
+
+			_, err := store.SaveCard(ctx, creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/44"), Holder: "Юрій", Brand: brandVisa, Fingerprint: visa}, auditEntry{})
+			var duplicate *duplicateCardError
+			require.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, 1, duplicate.ExistingID)
+
+			_, err = store.SaveCard(ctx, creditCard{Number: "378282246310005", ExpirationDate: testExpirationDate("02/31"), Holder: "Оксана", Brand: brandAmex}, auditEntry{})
+			require.NoError(t, err)
+
+			_, err = store.UpdateCard(ctx, creditCard{ID: 2, Number: "4263982640269299", ExpirationDate: testExpirationDate("01/30"), Holder: "Олег", Brand: brandVisa, Fingerprint: visa}, auditEntry{})
+			require.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, 1, duplicate.ExistingID)
+
+			_, err = store.UpdateCard(ctx, creditCard{ID: 1, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/44"), Holder: "Юра", Brand: brandVisa, Fingerprint: visa}, auditEntry{})
+			require.NoError(t, err)
+
+			require.NoError(t, store.DeleteCard(ctx, "", 1, auditEntry{}))
+			recreated, err := store.SaveCard(ctx, creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/44"), Holder: "Юрій", Brand: brandVisa, Fingerprint: visa}, auditEntry{})
+			require.NoError(t, err)
+
+			_, err = store.RestoreCard(ctx, "", 1, auditEntry{})
+			require.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, recreated.ID, duplicate.ExistingID)
+
+			_, err = store.SaveCard(ctx, creditCard{OwnerID: "bob", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/44"), Holder: "Богдан", Brand: brandVisa, Fingerprint: visa}, auditEntry{})
+			require.NoError(t, err)
+
+			groups, err := store.ListDuplicateCards(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []duplicateCardGroup{{Last4: "9299", Brand: brandVisa, CardIDs: []int{1, recreated.ID}}}, groups)
+		})
+	}
+}
+
+func TestCardStore_Ownership(t *testing.T) {
+	fingerprint := []byte("visa-fingerprint")
+
+	setupCards := []creditCard{
+		{OwnerID: "alice", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Аліса", Brand: brandVisa, Fingerprint: fingerprint},
+		{OwnerID: "bob", Number: "5375414100000000", ExpirationDate: testExpirationDate("01/30"), Holder: "Богдан", Brand: brandMastercard},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			seedCards(t, store, setupCards)
+
+			card, err := store.GetCard(ctx, "alice", 1)
+			require.NoError(t, err)
+			assert.Equal(t, "alice", card.OwnerID)
+
+			_, err = store.GetCard(ctx, "bob", 1)
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			page, err := store.ListCards(ctx, listCardsQuery{OwnerID: "bob", IncludeDeleted: true})
+			require.NoError(t, err)
+			assert.Equal(t, []int{2}, cardIDs(page.Cards))
+			assert.Equal(t, 1, page.Total)
+
+			_, err = store.UpdateCard(ctx, creditCard{ID: 1, OwnerID: "bob", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/44"), Holder: "Богдан", Brand: brandVisa}, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			holder := "Богдан"
+			_, err = store.PatchCard(ctx, "bob", 1, 0, cardPatch{Holder: &holder}, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+
+			assert.ErrorIs(t, store.DeleteCard(ctx, "bob", 1, auditEntry{}), errCreditCardNotFound)
+
+			require.NoError(t, store.DeleteCard(ctx, "alice", 1, auditEntry{}))
+			_, err = store.RestoreCard(ctx, "bob", 1, auditEntry{})
+			assert.ErrorIs(t, err, errCreditCardNotFound)
+			_, err = store.RestoreCard(ctx, "alice", 1, auditEntry{})
+			require.NoError(t, err)
+
+			card, err = store.SaveCard(ctx, creditCard{OwnerID: "bob", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Богдан", Brand: brandVisa, Fingerprint: fingerprint}, auditEntry{})
+			require.NoError(t, err)
+			assert.Equal(t, "bob", card.OwnerID)
+
+			_, err = store.SaveCard(ctx, creditCard{OwnerID: "alice", Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Аліса", Brand: brandVisa, Fingerprint: fingerprint}, auditEntry{})
+			var duplicate *duplicateCardError
+			require.ErrorAs(t, err, &duplicate)
+			assert.Equal(t, 1, duplicate.ExistingID)
+		})
+	}
+}
+
+func TestCardStore_CancelledContext(t *testing.T) {
+	testCases := map[string]func(ctx context.Context, store CardStore) error{
+		"save": func(ctx context.Context, store CardStore) error {
+			_, err := store.SaveCard(ctx, creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}, auditEntry{})
+			return err
+		},
+		"get": func(ctx context.Context, store CardStore) error {
+			_, err := store.GetCard(ctx, "", 1)
+			return err
+		},
+		"list": func(ctx context.Context, store CardStore) error {
+			_, err := store.ListCards(ctx, listCardsQuery{})
+			return err
+		},
+		"update": func(ctx context.Context, store CardStore) error {
+			_, err := store.UpdateCard(ctx, creditCard{ID: 1, Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}, auditEntry{})
+			return err
+		},
+		"delete": func(ctx context.Context, store CardStore) error {
+			return store.DeleteCard(ctx, "", 1, auditEntry{})
+		},
+		"restore": func(ctx context.Context, store CardStore) error {
+			_, err := store.RestoreCard(ctx, "", 1, auditEntry{})
+			return err
+		},
+		"purge": func(ctx context.Context, store CardStore) error {
+			_, err := store.PurgeDeletedCards(ctx, time.Now())
+			return err
+		},
+		"list_duplicates": func(ctx context.Context, store CardStore) error {
+			_, err := store.ListDuplicateCards(ctx)
+			return err
+		},
+	}
+
+	for backend, newStore := range cardStoreBackends() {
+		for name, call := range testCases {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				store := newStore(t)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				err := call(ctx, store)
+				assert.ErrorIs(t, err, context.Canceled)
+			})
+		}
+	}
+}
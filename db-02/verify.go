@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var errCardVerificationFailed = errors.New("card verification failed")
+
+type cardVerifier interface {
+	VerifyCard(ctx context.Context, card creditCard, cvv int) error
+}
+
+type simulatedCardVerifier struct {
+	declinedCVVs []int
+}
+
+func newSimulatedCardVerifier(declinedCVVs []int) *simulatedCardVerifier {
+	return &simulatedCardVerifier{declinedCVVs: declinedCVVs}
+}
+
+func (v *simulatedCardVerifier) VerifyCard(ctx context.Context, card creditCard, cvv int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if slices.Contains(v.declinedCVVs, cvv) {
+		return errCardVerificationFailed
+	}
+
+	return nil
+}
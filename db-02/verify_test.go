@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_simulatedCardVerifier(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := map[string]struct {
+		ctx    context.Context
+		cvv    int
+		expErr error
+	}{
+		"approved":  {ctx: context.Background(), cvv: 123},
+		"declined":  {ctx: context.Background(), cvv: 666, expErr: errCardVerificationFailed},
+		"cancelled": {ctx: cancelled, cvv: 123, expErr: context.Canceled},
+	}
+
+	verifier := newSimulatedCardVerifier([]int{666, 999})
+	card := creditCard{Number: "4263982640269299", ExpirationDate: testExpirationDate("12/43"), Holder: "Іванко"}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, verifier.VerifyCard(tc.ctx, card, tc.cvv), tc.expErr)
+		})
+	}
+}